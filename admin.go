@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// adminCommand is one staff-only "!bot <name> ..." command. It returns the private
+// reply for the staff member who issued it.
+type adminCommand func(ctx context.Context, cfg Config, evt ChatEvent, args []string) string
+
+// adminCommands lists the in-game staff commands, keyed by the word after the trigger.
+var adminCommands = map[string]adminCommand{
+	"strikes":  runStrikesCommand,
+	"waypoint": runWaypointCommand,
+}
+
+// playerCommand is a "!bot <name>" command any camper may use. Args is the exact
+// argument count, so "!bot no way!" still reaches the LLM instead of declining a
+// request, and Enabled hides the command when its feature is turned off.
+type playerCommand struct {
+	Args    int
+	Enabled func(Config) bool
+	Run     adminCommand
+}
+
+func consentEnabled(cfg Config) bool   { return cfg.Consent != nil }
+func waypointsEnabled(cfg Config) bool { return cfg.Waypoints != nil && cfg.EnableToolUse }
+
+// playerCommands lists the commands open to every camper.
+var playerCommands = map[string]playerCommand{
+	"yes":     {Args: 0, Enabled: consentEnabled, Run: runTeleportYes},
+	"no":      {Args: 0, Enabled: consentEnabled, Run: runTeleportNo},
+	"block":   {Args: 1, Enabled: consentEnabled, Run: runTeleportBlock},
+	"unblock": {Args: 1, Enabled: consentEnabled, Run: runTeleportUnblock},
+	"tpoff":   {Args: 0, Enabled: consentEnabled, Run: runTeleportOff},
+	"tpon":    {Args: 0, Enabled: consentEnabled, Run: runTeleportOn},
+	"sethome": {Args: 0, Enabled: waypointsEnabled, Run: runSetHomeCommand},
+}
+
+// parseAdminCommand recognizes "<trigger> <command> args..." for a known player
+// command, or an admin command sent by staff. Anything else, including a camper typing
+// "!bot strikes ...", is left to ordinary chat handling, where moderation sees it.
+//
+// 🎓 LEARNING NOTE: Commands skip moderation and the LLM, so a camper must never be able
+// to hide abuse behind a command prefix ("!bot strikes you idiot"). Camper commands that
+// trip a moderation rule are treated as chat too.
+func parseAdminCommand(cfg Config, evt ChatEvent) (string, []string, bool) {
+	fields := strings.Fields(evt.Text)
+	if len(fields) < 2 || !strings.EqualFold(fields[0], cfg.TriggerWord) {
+		return "", nil, false
+	}
+	name, args := strings.ToLower(fields[1]), fields[2:]
+	if isStaff(cfg, evt.Player) {
+		if _, ok := adminCommands[name]; ok {
+			return name, args, true
+		}
+	}
+	cmd, ok := playerCommands[name]
+	if !ok || !cmd.Enabled(cfg) || len(args) != cmd.Args {
+		return "", nil, false
+	}
+	if _, flagged := cfg.Moderation.Evaluate(evt.Text); flagged && cfg.EnableAlertTrigger && !isStaff(cfg, evt.Player) {
+		return "", nil, false
+	}
+	return name, args, true
+}
+
+// isStaff reports whether player is listed in MCCHATBOT_STAFF.
+func isStaff(cfg Config, player string) bool {
+	for _, name := range cfg.StaffNames {
+		if strings.EqualFold(name, player) {
+			return true
+		}
+	}
+	return false
+}
+
+// runAdminCommand checks the caller is staff, runs the command, and whispers the result
+// back so moderation details never show up in public chat. Player commands are open to
+// every camper.
+//
+// 🎓 LEARNING NOTE: Anyone can TYPE "!bot strikes", so we always check WHO typed it.
+// Never trust a command just because it looks official!
+func runAdminCommand(ctx context.Context, cfg Config, evt ChatEvent, name string, args []string) {
+	reply := "Sorry, only camp staff can use that command."
+	if cmd, ok := playerCommands[name]; ok {
+		reply = cmd.Run(ctx, cfg, evt, args)
+	} else if isStaff(cfg, evt.Player) {
+		log.Printf("[ADMIN] %s ran %s %s", evt.Player, name, strings.Join(args, " "))
+		reply = adminCommands[name](ctx, cfg, evt, args)
+	}
+	if err := tellPlayer(ctx, cfg, evt.Player, reply); err != nil {
+		log.Printf("admin reply error: %v", err)
+	}
+}
+
+// runStrikesCommand shows or clears a player's active strikes.
+func runStrikesCommand(_ context.Context, cfg Config, _ ChatEvent, args []string) string {
+	switch {
+	case len(args) == 1:
+		player := args[0]
+		strikes := cfg.Strikes.Active(player, time.Now())
+		if len(strikes) == 0 {
+			return fmt.Sprintf("%s has no active strikes.", player)
+		}
+		var parts []string
+		for _, s := range strikes {
+			parts = append(parts, fmt.Sprintf("%s %s", s.Time.Format("Jan 2 15:04"), s.Category))
+		}
+		return fmt.Sprintf("%s has %d active strike(s): %s", player, len(strikes), strings.Join(parts, "; "))
+	case len(args) == 2 && strings.EqualFold(args[0], "clear"):
+		player := args[1]
+		return fmt.Sprintf("Cleared %d strike(s) for %s.", cfg.Strikes.Clear(player), player)
+	}
+	return fmt.Sprintf("Usage: %s strikes <player> | %s strikes clear <player>", cfg.TriggerWord, cfg.TriggerWord)
+}
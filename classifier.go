@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// classifierPrompt tells the small moderation model exactly what to return. The JSON
+// schema below enforces the shape on providers that support structured output; the
+// prompt keeps providers that do not (Anthropic) on the same format.
+const classifierPrompt = `You are a chat moderator for a Minecraft summer camp server full of kids aged 8-14.
+Classify the single chat message you are given. Video-game violence is normal and fine ("kill the ender dragon", "I died to a creeper").
+Flag only messages that are genuinely unkind, unsafe, or inappropriate for kids.
+Reply with JSON only: {"flagged": bool, "category": one of ` + "`self_harm, grooming, sexual, threat, harassment, insult, profanity, substance, none`" + `, "confidence": number 0-1, "reason": short string}.`
+
+// classifierCategories are the labels the classifier may return; "none" means clean.
+var classifierCategories = []string{
+	string(categorySelfHarm), string(categoryGrooming), string(categorySexual), string(categoryThreat),
+	string(categoryHarassment), string(categoryInsult), string(categoryProfanity), string(categorySubstance), "none",
+}
+
+// classification is the classifier's structured verdict on one message.
+type classification struct {
+	Flagged    bool    `json:"flagged"`
+	Category   string  `json:"category"`
+	Confidence float64 `json:"confidence"`
+	Reason     string  `json:"reason"`
+	// Outcome records what the verdict did to the keyword result: confirmed, rejected,
+	// added, or ignored. It is filled in by secondOpinion, not by the model.
+	Outcome string `json:"outcome,omitempty"`
+}
+
+// classifierResponseFormat is the OpenAI-style structured output schema for the verdict.
+func classifierResponseFormat() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   "moderation_verdict",
+			"strict": true,
+			"schema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"flagged":    map[string]interface{}{"type": "boolean"},
+					"category":   map[string]interface{}{"type": "string", "enum": classifierCategories},
+					"confidence": map[string]interface{}{"type": "number"},
+					"reason":     map[string]interface{}{"type": "string"},
+				},
+				"required":             []string{"flagged", "category", "confidence", "reason"},
+				"additionalProperties": false,
+			},
+		},
+	}
+}
+
+// moderationClassifier asks a separate, small model for a second opinion on chat.
+// Verdicts are cached per normalized message, so a spammed line costs one call.
+//
+// 🎓 LEARNING NOTE: Keyword lists are fast but dumb - "kill" in "kill the ender dragon"
+// looks the same as a threat. A small AI model reads the whole sentence and understands
+// the context, so we use it as a second opinion.
+type moderationClassifier struct {
+	model     string
+	fallbacks []string
+	threshold float64
+	cacheSize int
+
+	mu    sync.Mutex
+	cache map[string]classification
+	order []string
+}
+
+func newModerationClassifier(model string, fallbacks []string, threshold float64, cacheSize int) *moderationClassifier {
+	return &moderationClassifier{
+		model:     model,
+		fallbacks: fallbacks,
+		threshold: threshold,
+		cacheSize: cacheSize,
+		cache:     make(map[string]classification),
+	}
+}
+
+// Classify returns the verdict for text, from the cache when the same message was seen
+// recently.
+func (c *moderationClassifier) Classify(ctx context.Context, cfg Config, text string) (classification, error) {
+	key := strings.Join(strings.Fields(strings.ToLower(text)), " ")
+	c.mu.Lock()
+	cached, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	// The classifier falls back only to its own small models; inheriting the chat
+	// fallbacks could quietly send every chat line to a large model.
+	classifyCfg := cfg
+	classifyCfg.FallbackModels = c.fallbacks
+	resp, err := doChatCompletion(ctx, classifyCfg, ChatRequest{
+		Model: c.model,
+		Messages: []Message{
+			{Role: "system", Content: classifierPrompt},
+			{Role: "user", Content: text},
+		},
+		MaxCompletionTokens: 120,
+		ResponseFormat:      classifierResponseFormat(),
+	})
+	if err != nil {
+		return classification{}, err
+	}
+	if len(resp.Choices) == 0 {
+		return classification{}, errors.New("classifier returned no choices")
+	}
+	verdict, err := parseClassification(resp.Choices[0].Message.Content)
+	if err != nil {
+		return classification{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.cache[key]; !exists && c.cacheSize > 0 {
+		if len(c.order) >= c.cacheSize {
+			delete(c.cache, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.cache[key] = verdict
+		c.order = append(c.order, key)
+	}
+	return verdict, nil
+}
+
+// parseClassification decodes the model's JSON, tolerating code fences or chatter
+// around the object from providers without structured output.
+func parseClassification(content string) (classification, error) {
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return classification{}, fmt.Errorf("classifier reply is not JSON: %q", content)
+	}
+	var verdict classification
+	if err := json.Unmarshal([]byte(content[start:end+1]), &verdict); err != nil {
+		return classification{}, fmt.Errorf("decode classifier reply: %w", err)
+	}
+	verdict.Category = strings.ToLower(strings.TrimSpace(verdict.Category))
+	if verdict.Category == "none" {
+		verdict.Flagged = false
+	}
+	return verdict, nil
+}
+
+// secondOpinion runs the classifier over a chat line and reconciles it with the keyword
+// verdict. A confident "clean" rejects a keyword hit, and a confident flag on a message
+// with no keywords adds one. Escalating categories (self-harm, grooming) are never
+// overruled, and the classifier is not even asked: a missed cry for help costs far more
+// than a false alarm, and staff should not wait on a network call and its retries. If
+// the classifier fails, the keyword result stands.
+func secondOpinion(ctx context.Context, cfg Config, evt ChatEvent, verdict *moderationVerdict) (*moderationVerdict, *classification) {
+	if cfg.Classifier == nil || !cfg.EnableAlertTrigger || (verdict != nil && verdict.Has(actionEscalate)) {
+		return verdict, nil
+	}
+	result, err := cfg.Classifier.Classify(ctx, cfg, evt.Text)
+	if err != nil {
+		log.Printf("classifier error (keeping keyword result): %v", err)
+		return verdict, nil
+	}
+	confident := result.Confidence >= cfg.Classifier.threshold
+	switch {
+	case verdict != nil && !result.Flagged && confident:
+		log.Printf("[MODERATION] classifier rejected %s hit %q from %s (%.2f): %s", verdict.Category, verdict.Match, evt.Player, result.Confidence, result.Reason)
+		result.Outcome = "rejected"
+		verdict = nil
+	case verdict != nil:
+		result.Outcome = "confirmed"
+	case result.Flagged && confident:
+		if added, ok := cfg.Moderation.VerdictFor(moderationCategory(result.Category), "classifier: "+result.Reason); ok {
+			result.Outcome = "added"
+			verdict = &added
+		} else {
+			result.Outcome = "ignored"
+		}
+	default:
+		result.Outcome = "ignored"
+	}
+	return verdict, &result
+}
+
+// classifierInvocation turns the classification into an interaction-log entry.
+func classifierInvocation(c *classification) ToolInvocation {
+	return ToolInvocation{
+		Name:      "moderation_classifier",
+		Arguments: fmt.Sprintf(`{"flagged":%t,"category":%q,"confidence":%.2f}`, c.Flagged, c.Category, c.Confidence),
+		Output:    fmt.Sprintf("%s: %s", c.Outcome, c.Reason),
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// modelRecorder fails every request with a server error and remembers which models
+// were asked.
+type modelRecorder struct {
+	mu     sync.Mutex
+	models []string
+}
+
+func (p *modelRecorder) Complete(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.models = append(p.models, req.Model)
+	return ChatResponse{}, &llmError{Kind: llmErrServer, Status: "503 Service Unavailable"}
+}
+
+func TestSecondOpinionSkipsEscalations(t *testing.T) {
+	provider := &scriptedProvider{}
+	cfg := Config{
+		LLM:                provider,
+		EnableAlertTrigger: true,
+		Moderation:         testModeration(t),
+		Classifier:         newModerationClassifier("small", nil, 0.7, 8),
+	}
+	verdict, _ := cfg.Moderation.Evaluate("i want to die")
+	reviewed, result := secondOpinion(context.Background(), cfg, ChatEvent{Player: "Alex", Text: "i want to die"}, &verdict)
+	if reviewed == nil || reviewed.Category != categorySelfHarm || result != nil {
+		t.Fatalf("reviewed = %+v, result = %+v; want the keyword verdict untouched", reviewed, result)
+	}
+	if len(provider.requests) != 0 {
+		t.Fatalf("classifier was called %d times for an escalation", len(provider.requests))
+	}
+}
+
+func TestClassifierUsesOwnFallbacks(t *testing.T) {
+	provider := &modelRecorder{}
+	cfg := Config{LLM: provider, FallbackModels: []string{"huge-chat-model"}}
+	classifier := newModerationClassifier("small", []string{"small-backup"}, 0.7, 8)
+
+	if _, err := classifier.Classify(context.Background(), cfg, "hello"); err == nil {
+		t.Fatal("expected an error when every classifier model fails")
+	}
+	if got := strings.Join(provider.models, ","); got != "small,small-backup" {
+		t.Fatalf("models tried = %s, want only the classifier's own", got)
+	}
+}
+
+func TestRejectedKeywordHitIsLogged(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "chat_history.log")
+	interactions, err := newRotatingLog(path, 0, false, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &scriptedProvider{responses: []Message{
+		{Content: `{"flagged": false, "category": "none", "confidence": 0.95, "reason": "talking about a mob"}`},
+	}}
+	console := &fakeConsole{}
+	cfg := Config{
+		RobotName:          "Alfred",
+		LLM:                provider,
+		EnableAlertTrigger: true,
+		Moderation:         testModeration(t),
+		Classifier:         newModerationClassifier("small", nil, 0.7, 8),
+		Console:            console,
+		Interactions:       interactions,
+	}
+	gate := newReplyGate(cfg, time.Now())
+	pool := newWorkerPool(ctx, 1, 8, &pipelineStats{})
+
+	handleChatEvent(ctx, cfg, gate, pool, ChatEvent{Kind: EventChat, Player: "Steve", Text: "you are trash at bedwars lol", Time: time.Now()})
+	drainLane(ctx, pool, "Steve")
+	if err := interactions.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if commands := console.sent(); len(commands) != 0 {
+		t.Fatalf("console commands = %q, want none after a rejected hit", commands)
+	}
+	records, err := queryInteractionLog(path, historyFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || len(records[0].Tools) != 1 || records[0].Tools[0].Name != "moderation_classifier" {
+		t.Fatalf("records = %+v, want one classifier entry", records)
+	}
+	var args struct {
+		Flagged    bool    `json:"flagged"`
+		Confidence float64 `json:"confidence"`
+	}
+	if err := json.Unmarshal([]byte(records[0].Tools[0].Arguments), &args); err != nil || args.Flagged || args.Confidence != 0.95 {
+		t.Fatalf("classifier arguments = %s (%v)", records[0].Tools[0].Arguments, err)
+	}
+	if !strings.HasPrefix(records[0].Tools[0].Output, "rejected") {
+		t.Fatalf("classifier output = %q, want rejected", records[0].Tools[0].Output)
+	}
+}
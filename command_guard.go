@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// selectorPattern finds Minecraft target selectors (@a, @e[type=...], @p, @r, @s, @n).
+// A single selector in an argument slot would let one command hit every entity.
+var selectorPattern = regexp.MustCompile(`@[aeprsn](?:\[|\b|$)`)
+
+// screenEscapePattern finds the sequences `screen -X stuff` rewrites before typing:
+// "^M" (or any caret plus @-_, a-z, or ?) becomes a control key, and a backslash starts
+// an escape such as "\015". Either one can press Enter in the middle of a command.
+// A caret followed by a space or digit ("^ ^ ^3" in local coordinates) stays literal.
+var screenEscapePattern = regexp.MustCompile(`\^[@-_a-z?]|\\`)
+
+// resourceLocationPattern matches namespaced IDs such as minecraft:overworld.
+var resourceLocationPattern = regexp.MustCompile(`^[a-z0-9_.-]+:[a-z0-9_./-]+$`)
+
+// validatePlayerName enforces Minecraft username rules on any name headed for a command
+// slot: letters, digits, and underscores only, 2-16 characters. Anything else - a
+// selector, a space followed by extra arguments, a newline - is refused before a
+// command string is ever built.
+//
+// 🎓 LEARNING NOTE: This is "command injection" defence. If the AI (or a tricky camper)
+// supplies the name "Steve\nop Steve", pasting it into "tp %s" would run TWO commands.
+// Checking the name first means the payload never reaches the console.
+func validatePlayerName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		return "", errors.New("missing player")
+	case strings.HasPrefix(name, "@"):
+		return "", fmt.Errorf("target selectors such as %q are not allowed", name)
+	case !isPlausiblePlayerName(name):
+		return "", fmt.Errorf("%q is not a valid Minecraft username", name)
+	}
+	return name, nil
+}
+
+// checkConsoleCommand is the last gate before the transport. Whatever built the
+// command, it must be a single line with no control characters, semicolons, or target
+// selectors; otherwise nothing is sent.
+func checkConsoleCommand(command string) error {
+	if strings.TrimSpace(command) == "" {
+		return errors.New("refusing empty console command")
+	}
+	for _, r := range command {
+		switch {
+		case r == '\r' || r == '\n':
+			return errors.New("refusing console command containing a line break")
+		case unicode.IsControl(r):
+			return fmt.Errorf("refusing console command containing control character %U", r)
+		case r == ';':
+			return errors.New("refusing console command containing ';'")
+		}
+	}
+	if selectorPattern.MatchString(command) {
+		return fmt.Errorf("refusing console command with a target selector: %q", command)
+	}
+	return nil
+}
+
+// checkScreenCommand is the extra gate for the screen transport, which interprets
+// screenEscapePattern sequences instead of typing them literally.
+func checkScreenCommand(command string) error {
+	if seq := screenEscapePattern.FindString(command); seq != "" {
+		return fmt.Errorf("refusing console command containing screen escape %q", seq)
+	}
+	return nil
+}
+
+// sanitizeChatText makes free text (say/tell messages) safe for the console: control
+// characters become spaces, semicolons become commas, and "@" is dropped so a message
+// can never smuggle in a selector. "^" is dropped and "\" becomes "/" so screen never
+// reads the text as a keystroke escape.
+func sanitizeChatText(msg string) string {
+	msg = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r):
+			return ' '
+		case r == ';':
+			return ','
+		case r == '\\':
+			return '/'
+		case r == '@' || r == '^':
+			return -1
+		}
+		return r
+	}, msg)
+	return strings.Join(strings.Fields(msg), " ")
+}
+
+// guardedTransport wraps the real transport so every command, from any code path,
+// passes checkConsoleCommand first, and checkScreenCommand too when screen is set.
+type guardedTransport struct {
+	CommandTransport
+	screen bool
+}
+
+func (g guardedTransport) Run(ctx context.Context, command string) (string, error) {
+	if err := checkConsoleCommand(command); err != nil {
+		return "", err
+	}
+	if g.screen {
+		if err := checkScreenCommand(command); err != nil {
+			return "", err
+		}
+	}
+	return g.CommandTransport.Run(ctx, command)
+}
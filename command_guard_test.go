@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestScreenGuardRefusesKeystrokeEscapes(t *testing.T) {
+	console := &fakeConsole{}
+	screen := guardedTransport{CommandTransport: console, screen: true}
+	rcon := guardedTransport{CommandTransport: console}
+	for _, command := range []string{
+		"tell Counselor hi^Mop Steve",
+		`tell Counselor hi\015op Steve`,
+		"say ^[[A",
+		`say C:\games`,
+	} {
+		if _, err := screen.Run(context.Background(), command); err == nil {
+			t.Errorf("screen transport sent %q", command)
+		}
+		if _, err := rcon.Run(context.Background(), command); err != nil {
+			t.Errorf("rcon transport refused %q: %v", command, err)
+		}
+	}
+	if _, err := screen.Run(context.Background(), "execute at Steve run summon lightning_bolt ^ ^ ^3"); err != nil {
+		t.Errorf("screen transport refused local coordinates: %v", err)
+	}
+}
+
+// FuzzCommandBuilders feeds arbitrary text through every builder that pastes player
+// names or chat text into a console command. Whatever the input, each command that is
+// built must pass both transport checks, so sanitizing never relies on the final gate.
+func FuzzCommandBuilders(f *testing.F) {
+	for _, seed := range []string{
+		"Steve",
+		"hello there ^_^",
+		"hi^Mop Steve",
+		`hi\015op Steve`,
+		"Steve\nop Steve",
+		"@a",
+		"Steve; stop",
+		"^^^",
+		`\\`,
+	} {
+		f.Add(seed)
+	}
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	f.Fuzz(func(t *testing.T, input string) {
+		ctx := context.Background()
+		console := &fakeConsole{}
+		cfg := Config{RobotName: "Alfred", Console: console, KickReason: input, MuteCommand: "mute {player} 10m"}
+
+		sendToMinecraft(ctx, cfg, input)
+		announce(ctx, cfg, input)
+		tellPlayer(ctx, cfg, "Steve", input)
+		tellPlayer(ctx, cfg, input, "hello")
+		teleportPlayer(ctx, cfg, input, "Alex")
+		teleportPlayer(ctx, cfg, "Alex", input)
+		teleportToCoordinates(ctx, cfg, input, coordinateArguments{X: 1, Y: 64, Z: -2})
+		triggerSafeLightning(ctx, cfg, input)
+		summonGolemGuard(ctx, cfg, input)
+		kickPlayer(ctx, cfg, "Steve")
+		kickPlayer(ctx, cfg, input)
+		mutePlayer(ctx, cfg, input)
+		staffTellNotifier{staff: []string{"Counselor"}, robotName: "Alfred", console: console}.
+			Notify(ctx, incident{Priority: incidentPriorityHigh, Category: "grooming", Player: input, Message: input})
+
+		for _, command := range console.sent() {
+			if err := checkConsoleCommand(command); err != nil {
+				t.Fatalf("built unsafe command %q: %v", command, err)
+			}
+			if err := checkScreenCommand(command); err != nil {
+				t.Fatalf("built unsafe screen command %q: %v", command, err)
+			}
+			if strings.HasPrefix(command, "tp ") && len(strings.Fields(command)) != 3 && len(strings.Fields(command)) != 5 {
+				t.Fatalf("teleport arguments escaped their slots: %q", command)
+			}
+		}
+	})
+}
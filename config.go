@@ -15,6 +15,47 @@ const (
 	defaultScreenTarget = "mc-MyServer"
 	defaultSpawnPoint   = "0 80 0"
 	defaultSpawnDim     = "minecraft:overworld"
+	defaultRconTimeout  = 5 * time.Second
+	defaultMemoryTurns  = 4
+	defaultMemoryShared = 2
+	defaultMemoryBudget = 1200
+	defaultMemoryIdle   = 15 * time.Minute
+	defaultLLMRetries   = 2
+	defaultLLMBackoff   = 500 * time.Millisecond
+	defaultLLMBackoffMx = 8 * time.Second
+	defaultLLMTimeout   = 20 * time.Second
+	defaultTemperature  = 0.7
+	defaultMaxTokens    = 200
+	defaultToolHops     = 3
+	defaultLLMFailure   = "I'm thinking too hard right now - ask me again in a moment!"
+	defaultPlayerBurst  = 2
+	defaultGlobalBurst  = 3
+	defaultGlobalRefill = 10 * time.Second
+	defaultQueueSize    = 10
+	defaultQueueMaxAge  = 2 * time.Minute
+	defaultQueuePlayer  = 2
+	defaultRateNotice   = "one at a time, friend! I'll get back to you in a moment."
+	defaultWorkers      = 4
+	defaultWorkerQueue  = 8
+	defaultToolParallel = 4
+	defaultChatBuffer   = 64
+	defaultMetricsEvery = time.Minute
+	defaultIncidentLog  = "incidents.log"
+	defaultIncidentCtx  = 10
+	defaultSelfHarmMsg  = "{player}, you matter and you are not alone. A camp counselor will check in with you very soon."
+	defaultStrikeFile   = "strikes.json"
+	defaultStrikeDecay  = 24 * time.Hour
+	defaultStrikeTiers  = "1=reminder,2=reminder+lightning,3=reminder+lightning+mute,4=kick+page"
+	defaultClassifyMin  = 0.7
+	defaultClassifyLRU  = 512
+	defaultReplyWords   = 30
+	defaultSafeFallback = "Let's keep the adventure friendly - what are you building today?"
+	defaultKickReason   = "Take a short break and come back ready to be kind."
+	defaultRosterSync   = 5 * time.Minute
+	defaultTPTimeout    = 60 * time.Second
+	defaultTPPrefsFile  = "teleport_prefs.json"
+	defaultWaypointFile = "waypoints.json"
+	defaultGroomingMsg  = "Quick safety reminder: never share where you live, your school, or other apps online. A counselor is on the way."
 
 	// 🎓 LEARNING NOTE: This is the "system prompt" - a 96-line instruction manual that shapes
 	// Alfred's entire personality! This is how we make AI assistants behave consistently.
@@ -87,7 +128,7 @@ TERMINOLOGY NOTES
 
 AVAILABLE TOOLS
 Use these functions whenever they help the campers (only move the requester, never a third party):
-1. teleport_player(...) – teleport the requester to another player, to specific coordinates, or to the configured spawn point.  
+1. teleport_player(...) – teleport the requester to another player (who is asked to accept first), to specific coordinates, to the spawn point, to their saved home, or to a named camp waypoint.  
 2. set_time(value) – change the world time (day/noon/night/midnight or ticks) if they politely ask.  
 3. set_weather(state) – clear rain, start rain, or summon a storm when it keeps the fun rolling.  
 4. floating_cat(player?) – conjure a floating, motionless cat buddy.  
@@ -110,6 +151,8 @@ Be the magical, positive, energizing center of the server.
 Short replies, big heart, maximum fun, always safe.
 `
 	defaultResponseLog  = "chat_history.log"
+	defaultLogMaxMB     = 50
+	defaultLogRetention = 30 * 24 * time.Hour
 	teleportToolName    = "teleport_player"
 	timeToolName        = "set_time"
 	weatherToolName     = "set_weather"
@@ -127,43 +170,7 @@ Short replies, big heart, maximum fun, always safe.
 
 var (
 	defaultEngageKeywords = []string{"help", "how", "where", "why", "what", "can", "anyone", "tip", "idea", "question"}
-	defaultAlertKeywords  = []string{
-		// Core toxicity & insults
-		"stupid", "idiot", "hate", "dumb", "shut up", "noob", "trash", "bully",
-		"loser", "moron", "clown", "crybaby", "lame", "garbage", "worthless",
-		"pathetic", "annoying", "nobody likes you",
-
-		// Aggressive / threat language
-		"kill", "kys", "die ", "die.", "i'll kill", "i will kill",
-		"hurt you", "break your", "fight me", "pull up",
-		"head",
-
-		// Self-harm expressions
-		"i want to die", "i wanna die", "i hate myself",
-		"i'm done", "i'm useless", "no one cares", "kill myself",
-		"suicide", "self harm", "cut myself",
-
-		// Profanity (mild to medium)
-		"wtf", "omfg", "bs", "damn", "hell", "bitch",
-		"ass", "dumbass", "jackass", "shit", "fuck", "f off", "f u",
-
-		// Harassment / bullying escalators
-		"go away", "get lost", "stop talking", "you don't belong",
-		"everyone hates you", "no one likes you",
-
-		// Sexual / inappropriate cues (kid-safe subset)
-		"nsfw", "nude", "nudes", "sex", "sext", "porn",
-		"horny", "send pics", "send a pic", "send photo",
-
-		// Grooming red flags
-		"where do you live", "what's your address", "what school",
-		"what grade", "are you alone", "are your parents home",
-		"snapchat", "snap me", "dm me", "private chat",
-
-		// Substance / violence indicators
-		"weed", "vape", "drugs", "alcohol", "vodka",
-		"stab", "shoot", "gun", "bomb",
-	}
+	defaultBannedTopics   = []string{"politics", "election", "religion", "dating", "girlfriend", "boyfriend", "gambling", "casino", "lottery"}
 
 	teleportRegex  = regexp.MustCompile(`(?i)\b(?:tp|teleport)\b`)
 	timeKeywordSet = map[string]string{
@@ -184,26 +191,130 @@ var (
 )
 
 type Config struct {
-	APIKey                string
-	Model                 string
-	LogPath               string
-	ScreenSession         string
-	SpawnPoint            [3]float64
-	SpawnDimension        string
-	SystemPrompt          string
-	ReplyCooldown         time.Duration
-	TriggerWord           string
-	RobotName             string
-	EngageWords           []string
-	AlertWords            []string
-	ResponseLog           string
-	EnableNameTrigger     bool
-	EnablePrefixTrigger   bool
-	EnableQuestionTrigger bool
-	EnableAlertTrigger    bool
-	EnableToolUse         bool
-	EnableWorldTool       bool
-	EnableEasterEggs      bool
+	APIKey                 string
+	Model                  string
+	LLMProvider            string
+	LLMBaseURL             string
+	FallbackModels         []string
+	LLMRetries             int
+	LLMBackoff             time.Duration
+	LLMBackoffMax          time.Duration
+	LLMRequestTimeout      time.Duration
+	Temperature            float64
+	MaxTokens              int
+	ToolHops               int
+	LLMFailureMessage      string
+	LogPath                string
+	LogFormat              string
+	ScreenSession          string
+	Transport              string
+	RconAddress            string
+	RconPassword           string
+	RconTimeout            time.Duration
+	SpawnPoint             [3]float64
+	SpawnDimension         string
+	EnableTeleportConsent  bool
+	TeleportTimeout        time.Duration
+	TeleportPrefsFile      string
+	WaypointFile           string
+	ToolPolicyFile         string
+	SystemPrompt           string
+	ReplyCooldown          time.Duration
+	PlayerBurst            int
+	GlobalBurst            int
+	GlobalRefill           time.Duration
+	ReplyQueueSize         int
+	ReplyQueueMaxAge       time.Duration
+	ReplyQueuePerPlayer    int
+	RateLimitNotice        string
+	Workers                int
+	WorkerQueue            int
+	ToolParallelism        int
+	ChatBuffer             int
+	MetricsInterval        time.Duration
+	RosterSync             time.Duration
+	TriggerWord            string
+	RobotName              string
+	EngageWords            []string
+	AlertWords             []string
+	AllowWords             []string
+	ModerationPolicy       string
+	MuteCommand            string
+	StaffNames             []string
+	IncidentLog            string
+	IncidentWebhook        string
+	IncidentContext        int
+	SelfHarmMessage        string
+	GroomingMessage        string
+	StrikeFile             string
+	StrikeDecay            time.Duration
+	StrikeTiers            []strikeTier
+	KickReason             string
+	ClassifierModel        string
+	ClassifierFallbacks    []string
+	ClassifierThreshold    float64
+	ClassifierCache        int
+	EnableOutputFilter     bool
+	ReplyMaxWords          int
+	BannedTopics           []string
+	SafeFallback           string
+	ResponseLog            string
+	ResponseLogMaxMB       int
+	ResponseLogDaily       bool
+	ResponseLogCompress    bool
+	ResponseLogRetention   time.Duration
+	HistoryDB              string
+	TranscriptFile         string
+	EnableNameTrigger      bool
+	EnablePrefixTrigger    bool
+	EnableQuestionTrigger  bool
+	EnableAlertTrigger     bool
+	EnableToolUse          bool
+	EnableWorldTool        bool
+	EnableEasterEggs       bool
+	EnableJoinGreeting     bool
+	EnableDeathComfort     bool
+	EnableAdvancementCheer bool
+	MemoryExchanges        int
+	MemoryChannelExchanges int
+	MemoryTokenBudget      int
+	MemoryIdleExpiry       time.Duration
+	MemoryFile             string
+
+	// Console is the live command transport built from the settings above.
+	Console CommandTransport
+	// LLM is the chat-completions backend selected by LLMProvider.
+	LLM ChatProvider
+	// Memory remembers recent exchanges per player and channel for callLLM.
+	Memory *conversationMemory
+	// Moderation classifies alert phrases by category and maps them to actions.
+	Moderation *moderationEngine
+	// Strikes counts each player's recent moderation hits for graduated consequences.
+	Strikes *strikeLedger
+	// Classifier is the optional LLM second opinion on moderation; nil when disabled.
+	Classifier *moderationClassifier
+	// OutputFilter checks every line before it is posted; nil when disabled.
+	OutputFilter *outputFilter
+	// Incidents tells humans about escalated chat; nil when nothing is configured.
+	Incidents incidentNotifier
+	// RecentChat holds the last few chat lines quoted in incident records.
+	RecentChat *recentChat
+	// Roster tracks who is online so tools only target players on the server.
+	Roster *playerRoster
+	// Consent holds teleport requests awaiting the target's yes; nil when disabled.
+	Consent *teleportConsent
+	// Waypoints stores named camp locations and each camper's home.
+	Waypoints *waypointStore
+	// ToolPolicy limits which tools each player group may use; nil leaves all tools open.
+	ToolPolicy *toolPolicy
+	// Tools is the registry of built-in and custom tools the model may call.
+	Tools *toolRegistry
+	// Interactions writes the rotating JSONL interaction log; nil when logging is off.
+	Interactions *rotatingLog
+	// History is the optional SQLite interaction store; nil unless MCCHATBOT_HISTORY_DB is set.
+	History *historyStore
+	// Transcript records every chat line and server event; nil unless MCCHATBOT_TRANSCRIPT is set.
+	Transcript *transcriptRecorder
 }
 
 // loadConfig collects environment variables, falls back to defaults, and ensures required
@@ -232,31 +343,194 @@ func loadConfig() (Config, error) {
 	if err != nil {
 		return Config{}, fmt.Errorf("invalid spawn point: %w", err)
 	}
+	logFormat, err := lookupLogFormat(os.Getenv("MCCHATBOT_LOG_FORMAT"))
+	if err != nil {
+		return Config{}, err
+	}
 	toolUse := envBoolOr("MCCHATBOT_ENABLE_TOOL_USE", true)
 	cfg := Config{
-		APIKey:                os.Getenv("DEMETERICS_API_KEY"),
-		Model:                 envOr("DEMETERICS_MODEL", defaultModel),
-		LogPath:               envOr("MCCHATBOT_LOG_PATH", defaultLogPath),
-		ScreenSession:         envOr("MCCHATBOT_SCREEN_NAME", defaultScreenTarget),
-		SpawnPoint:            spawnPoint,
-		SpawnDimension:        strings.TrimSpace(envOr("MCCHATBOT_SPAWN_DIMENSION", defaultSpawnDim)),
-		SystemPrompt:          systemPrompt,
-		ReplyCooldown:         cooldown,
-		TriggerWord:           trigger,
-		RobotName:             robotName,
-		EngageWords:           parseWordList(os.Getenv("MCCHATBOT_ENGAGE_WORDS"), defaultEngageKeywords),
-		AlertWords:            parseWordList(os.Getenv("MCCHATBOT_ALERT_WORDS"), defaultAlertKeywords),
-		ResponseLog:           envOr("MCCHATBOT_RESPONSE_LOG", defaultResponseLog),
-		EnableNameTrigger:     envBoolOr("MCCHATBOT_ENABLE_NAME_TRIGGER", true),
-		EnablePrefixTrigger:   envBoolOr("MCCHATBOT_ENABLE_PREFIX_TRIGGER", true),
-		EnableQuestionTrigger: envBoolOr("MCCHATBOT_ENABLE_QUESTION_TRIGGER", true),
-		EnableAlertTrigger:    envBoolOr("MCCHATBOT_ENABLE_ALERT_TRIGGER", true),
-		EnableToolUse:         toolUse,
-		EnableWorldTool:       envBoolOr("MCCHATBOT_ENABLE_WORLD_TOOL", true),
-		EnableEasterEggs:      envBoolOr("MCCHATBOT_ENABLE_EASTER_EGGS", true),
-	}
-	if cfg.APIKey == "" {
-		return Config{}, fmt.Errorf("DEMETERICS_API_KEY is required")
+		APIKey:                 envOr("MCCHATBOT_LLM_API_KEY", os.Getenv("DEMETERICS_API_KEY")),
+		Model:                  envOr("MCCHATBOT_LLM_MODEL", envOr("DEMETERICS_MODEL", defaultModel)),
+		LLMProvider:            strings.ToLower(strings.TrimSpace(envOr("MCCHATBOT_LLM_PROVIDER", providerDemeterics))),
+		LLMBaseURL:             strings.TrimSpace(os.Getenv("MCCHATBOT_LLM_BASE_URL")),
+		FallbackModels:         parseCSV(os.Getenv("MCCHATBOT_LLM_FALLBACK_MODELS")),
+		LLMRetries:             envIntOr("MCCHATBOT_LLM_RETRIES", defaultLLMRetries),
+		LLMBackoff:             envDurationOr("MCCHATBOT_LLM_BACKOFF", defaultLLMBackoff),
+		LLMBackoffMax:          envDurationOr("MCCHATBOT_LLM_BACKOFF_MAX", defaultLLMBackoffMx),
+		LLMRequestTimeout:      envDurationOr("MCCHATBOT_LLM_TIMEOUT", defaultLLMTimeout),
+		Temperature:            envFloatOr("MCCHATBOT_LLM_TEMPERATURE", defaultTemperature),
+		MaxTokens:              envIntOr("MCCHATBOT_LLM_MAX_TOKENS", defaultMaxTokens),
+		ToolHops:               envIntOr("MCCHATBOT_TOOL_HOPS", defaultToolHops),
+		LLMFailureMessage:      envOrAllowEmpty("MCCHATBOT_LLM_FAILURE_MESSAGE", defaultLLMFailure),
+		LogPath:                envOr("MCCHATBOT_LOG_PATH", defaultLogPath),
+		LogFormat:              logFormat,
+		ScreenSession:          envOr("MCCHATBOT_SCREEN_NAME", defaultScreenTarget),
+		Transport:              strings.ToLower(strings.TrimSpace(envOr("MCCHATBOT_TRANSPORT", transportScreen))),
+		RconAddress:            strings.TrimSpace(os.Getenv("MCCHATBOT_RCON_ADDR")),
+		RconPassword:           os.Getenv("MCCHATBOT_RCON_PASSWORD"),
+		RconTimeout:            envDurationOr("MCCHATBOT_RCON_TIMEOUT", defaultRconTimeout),
+		SpawnPoint:             spawnPoint,
+		SpawnDimension:         strings.TrimSpace(envOr("MCCHATBOT_SPAWN_DIMENSION", defaultSpawnDim)),
+		EnableTeleportConsent:  envBoolOr("MCCHATBOT_TELEPORT_CONSENT", true),
+		TeleportTimeout:        envDurationOr("MCCHATBOT_TELEPORT_TIMEOUT", defaultTPTimeout),
+		TeleportPrefsFile:      envOrAllowEmpty("MCCHATBOT_TELEPORT_PREFS", defaultTPPrefsFile),
+		WaypointFile:           envOrAllowEmpty("MCCHATBOT_WAYPOINT_FILE", defaultWaypointFile),
+		ToolPolicyFile:         strings.TrimSpace(os.Getenv("MCCHATBOT_TOOL_POLICY")),
+		SystemPrompt:           systemPrompt,
+		ReplyCooldown:          cooldown,
+		PlayerBurst:            envIntOr("MCCHATBOT_PLAYER_BURST", defaultPlayerBurst),
+		GlobalBurst:            envIntOr("MCCHATBOT_GLOBAL_BURST", defaultGlobalBurst),
+		GlobalRefill:           envDurationOr("MCCHATBOT_GLOBAL_REFILL", defaultGlobalRefill),
+		ReplyQueueSize:         envIntOr("MCCHATBOT_QUEUE_SIZE", defaultQueueSize),
+		ReplyQueueMaxAge:       envDurationOr("MCCHATBOT_QUEUE_MAX_AGE", defaultQueueMaxAge),
+		ReplyQueuePerPlayer:    envIntOr("MCCHATBOT_QUEUE_PER_PLAYER", defaultQueuePlayer),
+		RateLimitNotice:        envOrAllowEmpty("MCCHATBOT_RATE_NOTICE", defaultRateNotice),
+		Workers:                envIntOr("MCCHATBOT_WORKERS", defaultWorkers),
+		WorkerQueue:            envIntOr("MCCHATBOT_WORKER_QUEUE", defaultWorkerQueue),
+		ToolParallelism:        envIntOr("MCCHATBOT_TOOL_PARALLEL", defaultToolParallel),
+		ChatBuffer:             envIntOr("MCCHATBOT_CHAT_BUFFER", defaultChatBuffer),
+		MetricsInterval:        envDurationOr("MCCHATBOT_METRICS_INTERVAL", defaultMetricsEvery),
+		RosterSync:             envDurationOr("MCCHATBOT_ROSTER_SYNC", defaultRosterSync),
+		TriggerWord:            trigger,
+		RobotName:              robotName,
+		EngageWords:            parseWordList(os.Getenv("MCCHATBOT_ENGAGE_WORDS"), defaultEngageKeywords),
+		AlertWords:             parseWordList(os.Getenv("MCCHATBOT_ALERT_WORDS"), nil),
+		AllowWords:             parseWordList(os.Getenv("MCCHATBOT_ALLOW_WORDS"), nil),
+		ModerationPolicy:       os.Getenv("MCCHATBOT_MODERATION_POLICY"),
+		MuteCommand:            strings.TrimSpace(os.Getenv("MCCHATBOT_MUTE_COMMAND")),
+		StaffNames:             parseCSV(os.Getenv("MCCHATBOT_STAFF")),
+		IncidentLog:            envOrAllowEmpty("MCCHATBOT_INCIDENT_LOG", defaultIncidentLog),
+		IncidentWebhook:        strings.TrimSpace(os.Getenv("MCCHATBOT_INCIDENT_WEBHOOK")),
+		IncidentContext:        envIntOr("MCCHATBOT_INCIDENT_CONTEXT", defaultIncidentCtx),
+		SelfHarmMessage:        envOrAllowEmpty("MCCHATBOT_SELF_HARM_MESSAGE", defaultSelfHarmMsg),
+		GroomingMessage:        envOrAllowEmpty("MCCHATBOT_GROOMING_MESSAGE", defaultGroomingMsg),
+		StrikeFile:             envOrAllowEmpty("MCCHATBOT_STRIKE_FILE", defaultStrikeFile),
+		StrikeDecay:            envDurationOr("MCCHATBOT_STRIKE_DECAY", defaultStrikeDecay),
+		KickReason:             envOr("MCCHATBOT_KICK_REASON", defaultKickReason),
+		ClassifierModel:        strings.TrimSpace(os.Getenv("MCCHATBOT_CLASSIFIER_MODEL")),
+		ClassifierFallbacks:    parseCSV(os.Getenv("MCCHATBOT_CLASSIFIER_FALLBACK_MODELS")),
+		ClassifierThreshold:    envFloatOr("MCCHATBOT_CLASSIFIER_THRESHOLD", defaultClassifyMin),
+		ClassifierCache:        envIntOr("MCCHATBOT_CLASSIFIER_CACHE", defaultClassifyLRU),
+		EnableOutputFilter:     envBoolOr("MCCHATBOT_ENABLE_OUTPUT_FILTER", true),
+		ReplyMaxWords:          envIntOr("MCCHATBOT_REPLY_MAX_WORDS", defaultReplyWords),
+		BannedTopics:           parseWordList(os.Getenv("MCCHATBOT_BANNED_TOPICS"), defaultBannedTopics),
+		SafeFallback:           envOr("MCCHATBOT_SAFE_FALLBACK", defaultSafeFallback),
+		ResponseLog:            envOr("MCCHATBOT_RESPONSE_LOG", defaultResponseLog),
+		ResponseLogMaxMB:       envIntOr("MCCHATBOT_RESPONSE_LOG_MAX_MB", defaultLogMaxMB),
+		ResponseLogDaily:       envBoolOr("MCCHATBOT_RESPONSE_LOG_DAILY", true),
+		ResponseLogCompress:    envBoolOr("MCCHATBOT_RESPONSE_LOG_COMPRESS", true),
+		ResponseLogRetention:   envDurationOr("MCCHATBOT_RESPONSE_LOG_RETENTION", defaultLogRetention),
+		HistoryDB:              strings.TrimSpace(os.Getenv("MCCHATBOT_HISTORY_DB")),
+		TranscriptFile:         strings.TrimSpace(os.Getenv("MCCHATBOT_TRANSCRIPT")),
+		EnableNameTrigger:      envBoolOr("MCCHATBOT_ENABLE_NAME_TRIGGER", true),
+		EnablePrefixTrigger:    envBoolOr("MCCHATBOT_ENABLE_PREFIX_TRIGGER", true),
+		EnableQuestionTrigger:  envBoolOr("MCCHATBOT_ENABLE_QUESTION_TRIGGER", true),
+		EnableAlertTrigger:     envBoolOr("MCCHATBOT_ENABLE_ALERT_TRIGGER", true),
+		EnableToolUse:          toolUse,
+		EnableWorldTool:        envBoolOr("MCCHATBOT_ENABLE_WORLD_TOOL", true),
+		EnableEasterEggs:       envBoolOr("MCCHATBOT_ENABLE_EASTER_EGGS", true),
+		EnableJoinGreeting:     envBoolOr("MCCHATBOT_ENABLE_JOIN_GREETING", true),
+		EnableDeathComfort:     envBoolOr("MCCHATBOT_ENABLE_DEATH_COMFORT", true),
+		EnableAdvancementCheer: envBoolOr("MCCHATBOT_ENABLE_ADVANCEMENT_CHEER", true),
+		MemoryExchanges:        envIntOr("MCCHATBOT_MEMORY_EXCHANGES", defaultMemoryTurns),
+		MemoryChannelExchanges: envIntOr("MCCHATBOT_MEMORY_CHANNEL_EXCHANGES", defaultMemoryShared),
+		MemoryTokenBudget:      envIntOr("MCCHATBOT_MEMORY_TOKEN_BUDGET", defaultMemoryBudget),
+		MemoryIdleExpiry:       envDurationOr("MCCHATBOT_MEMORY_IDLE", defaultMemoryIdle),
+		MemoryFile:             strings.TrimSpace(os.Getenv("MCCHATBOT_MEMORY_FILE")),
+	}
+	if !resourceLocationPattern.MatchString(cfg.SpawnDimension) {
+		return Config{}, fmt.Errorf("MCCHATBOT_SPAWN_DIMENSION %q must be a namespaced ID such as minecraft:overworld", cfg.SpawnDimension)
+	}
+	for _, name := range cfg.StaffNames {
+		if _, err := validatePlayerName(name); err != nil {
+			return Config{}, fmt.Errorf("MCCHATBOT_STAFF: %w", err)
+		}
+	}
+	if cfg.APIKey == "" && providerNeedsKey(cfg.LLMProvider) {
+		return Config{}, fmt.Errorf("DEMETERICS_API_KEY (or MCCHATBOT_LLM_API_KEY) is required for the %s provider", cfg.LLMProvider)
+	}
+	provider, err := newChatProvider(cfg)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.LLM = provider
+	memory, err := newConversationMemory(cfg.MemoryExchanges, cfg.MemoryChannelExchanges, cfg.MemoryTokenBudget, cfg.MemoryIdleExpiry, cfg.MemoryFile)
+	if err != nil {
+		return Config{}, fmt.Errorf("conversation memory: %w", err)
+	}
+	cfg.Memory = memory
+	moderation, err := newModerationEngine(cfg.AlertWords, cfg.AllowWords, cfg.ModerationPolicy)
+	if err != nil {
+		return Config{}, fmt.Errorf("moderation: %w", err)
+	}
+	cfg.Moderation = moderation
+	tiers, err := parseStrikeTiers(envOrAllowEmpty("MCCHATBOT_STRIKE_TIERS", defaultStrikeTiers))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.StrikeTiers = tiers
+	strikes, err := newStrikeLedger(cfg.StrikeFile, cfg.StrikeDecay)
+	if err != nil {
+		return Config{}, fmt.Errorf("strike ledger: %w", err)
+	}
+	cfg.Strikes = strikes
+	if cfg.EnableOutputFilter {
+		cfg.OutputFilter = newOutputFilter(cfg.ReplyMaxWords, cfg.Moderation, cfg.BannedTopics)
+	}
+	if cfg.ClassifierModel != "" {
+		cfg.Classifier = newModerationClassifier(cfg.ClassifierModel, cfg.ClassifierFallbacks, cfg.ClassifierThreshold, cfg.ClassifierCache)
+	}
+	console, err := newCommandTransport(cfg)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Console = console
+	cfg.RecentChat = newRecentChat(cfg.IncidentContext)
+	cfg.Roster = newPlayerRoster()
+	policy, err := loadToolPolicy(cfg.ToolPolicyFile, cfg.StaffNames)
+	if err != nil {
+		return Config{}, fmt.Errorf("tool policy: %w", err)
+	}
+	cfg.ToolPolicy = policy
+	customTools, err := loadCustomTools(strings.TrimSpace(os.Getenv("MCCHATBOT_CUSTOM_TOOLS")))
+	if err != nil {
+		return Config{}, fmt.Errorf("custom tools: %w", err)
+	}
+	tools := builtinTools()
+	for _, custom := range customTools {
+		tools = append(tools, custom)
+	}
+	if cfg.Tools, err = newToolRegistry(cfg, tools...); err != nil {
+		return Config{}, fmt.Errorf("custom tools: %w", err)
+	}
+	waypoints, err := newWaypointStore(cfg.WaypointFile)
+	if err != nil {
+		return Config{}, fmt.Errorf("waypoints: %w", err)
+	}
+	cfg.Waypoints = waypoints
+	if cfg.EnableTeleportConsent {
+		consent, err := newTeleportConsent(cfg.TeleportTimeout, cfg.TeleportPrefsFile)
+		if err != nil {
+			return Config{}, fmt.Errorf("teleport prefs: %w", err)
+		}
+		cfg.Consent = consent
+	}
+	cfg.Incidents = newIncidentNotifier(cfg)
+	maxBytes := int64(cfg.ResponseLogMaxMB) << 20
+	interactions, err := newRotatingLog(cfg.ResponseLog, maxBytes, cfg.ResponseLogDaily, cfg.ResponseLogCompress, cfg.ResponseLogRetention)
+	if err != nil {
+		return Config{}, fmt.Errorf("response log: %w", err)
+	}
+	cfg.Interactions = interactions
+	if cfg.History, err = openHistoryStore(cfg.HistoryDB); err != nil {
+		cfg.Interactions.Close()
+		return Config{}, fmt.Errorf("history db: %w", err)
+	}
+	// The transcript rotates and expires on the same schedule as the interaction log.
+	if cfg.Transcript, err = newTranscriptRecorder(cfg.TranscriptFile, maxBytes, cfg.ResponseLogDaily, cfg.ResponseLogCompress, cfg.ResponseLogRetention); err != nil {
+		cfg.Interactions.Close()
+		cfg.History.Close()
+		return Config{}, fmt.Errorf("transcript: %w", err)
 	}
 	return cfg, nil
 }
@@ -284,6 +558,58 @@ func envBoolOr(key string, fallback bool) bool {
 	return fallback
 }
 
+// envDurationOr parses Go duration strings ("30s", "15m") and keeps the fallback when the
+// variable is unset or malformed, matching how the reply cooldown has always behaved.
+func envDurationOr(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if dur, err := time.ParseDuration(strings.TrimSpace(v)); err == nil {
+			return dur
+		}
+	}
+	return fallback
+}
+
+// envIntOr parses whole-number settings such as history sizes, falling back on errors.
+func envIntOr(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+// envFloatOr parses decimal settings such as confidence thresholds, falling back on errors.
+func envFloatOr(key string, fallback float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+// envOrAllowEmpty is like envOr, but an explicitly empty variable wins over the fallback
+// so admins can switch a message off by setting it to "".
+func envOrAllowEmpty(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return strings.TrimSpace(v)
+	}
+	return fallback
+}
+
+// parseCSV splits a comma-separated list while preserving case, for values such as
+// model IDs where casing matters.
+func parseCSV(raw string) []string {
+	var items []string
+	for _, part := range strings.Split(raw, ",") {
+		if item := strings.TrimSpace(part); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // parseWordList splits a comma-separated string of words, normalizes casing, and keeps
 // a list of defaults if the environment variable is empty. It preserves deterministic
 // behavior even when admins supply odd whitespace or casing.
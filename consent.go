@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errNoVisitors is what a requester hears when the target opted out or blocked them.
+// Both cases share one message so a block is never revealed.
+var errNoVisitors = errors.New("isn't taking teleport visitors right now")
+
+// teleportRequest is one camper asking to teleport to another.
+type teleportRequest struct {
+	From    string
+	To      string
+	Expires time.Time
+}
+
+// teleportPrefs is a camper's standing answer to teleport requests.
+type teleportPrefs struct {
+	OptOut  bool     `json:"opt_out,omitempty"`
+	Blocked []string `json:"blocked,omitempty"`
+}
+
+// teleportConsent holds pending player-to-player teleport requests until the target
+// says yes or no, plus each camper's block list and opt-out. Pending requests live only
+// in memory and expire; preferences are saved to disk when a path is configured.
+//
+// 🎓 LEARNING NOTE: Teleporting onto someone without asking is like walking into their
+// house uninvited! Now Alfred knocks first: "Steve wants to visit - say !bot yes".
+type teleportConsent struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	path    string
+	pending map[string]teleportRequest // keyed by lowercase target
+	prefs   map[string]*teleportPrefs  // keyed by lowercase player
+}
+
+// newTeleportConsent builds the tracker and reloads saved preferences. A missing file
+// is fine; a corrupt one is reported.
+func newTeleportConsent(timeout time.Duration, path string) (*teleportConsent, error) {
+	c := &teleportConsent{
+		timeout: timeout,
+		path:    path,
+		pending: make(map[string]teleportRequest),
+		prefs:   make(map[string]*teleportPrefs),
+	}
+	if path == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.prefs); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Request records from's wish to visit to. It fails when the target opted out, blocked
+// the requester, or is already deciding on someone else's request.
+func (c *teleportConsent) Request(from, to string, now time.Time) (teleportRequest, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if strings.EqualFold(from, to) {
+		return teleportRequest{}, errors.New("you can't teleport to yourself")
+	}
+	key := strikeKey(to)
+	if prefs := c.prefs[key]; prefs != nil && (prefs.OptOut || containsFold(prefs.Blocked, from)) {
+		return teleportRequest{}, fmt.Errorf("%s %w", to, errNoVisitors)
+	}
+	if existing, ok := c.pending[key]; ok && now.Before(existing.Expires) && !strings.EqualFold(existing.From, from) {
+		return teleportRequest{}, fmt.Errorf("%s already has a teleport request waiting; try again in a minute", to)
+	}
+	req := teleportRequest{From: from, To: to, Expires: now.Add(c.timeout)}
+	c.pending[key] = req
+	return req, nil
+}
+
+// Answer resolves the target's pending request, if any is still live.
+func (c *teleportConsent) Answer(target string, now time.Time) (teleportRequest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := strikeKey(target)
+	req, ok := c.pending[key]
+	delete(c.pending, key)
+	if !ok || !now.Before(req.Expires) {
+		return teleportRequest{}, false
+	}
+	return req, true
+}
+
+// Expire drops requests whose time ran out and returns them so requesters can be told.
+func (c *teleportConsent) Expire(now time.Time) []teleportRequest {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var expired []teleportRequest
+	for key, req := range c.pending {
+		if !now.Before(req.Expires) {
+			expired = append(expired, req)
+			delete(c.pending, key)
+		}
+	}
+	return expired
+}
+
+// SetOptOut turns all incoming teleport requests off (true) or back on (false).
+func (c *teleportConsent) SetOptOut(player string, optOut bool) {
+	c.updatePrefs(player, func(p *teleportPrefs) { p.OptOut = optOut })
+}
+
+// Block stops other from sending player teleport requests.
+func (c *teleportConsent) Block(player, other string) {
+	c.updatePrefs(player, func(p *teleportPrefs) {
+		if !containsFold(p.Blocked, other) {
+			p.Blocked = append(p.Blocked, other)
+		}
+	})
+}
+
+// Unblock lets other send player teleport requests again.
+func (c *teleportConsent) Unblock(player, other string) {
+	c.updatePrefs(player, func(p *teleportPrefs) {
+		kept := p.Blocked[:0]
+		for _, name := range p.Blocked {
+			if !strings.EqualFold(name, other) {
+				kept = append(kept, name)
+			}
+		}
+		p.Blocked = kept
+	})
+}
+
+func (c *teleportConsent) updatePrefs(player string, change func(*teleportPrefs)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := strikeKey(player)
+	prefs := c.prefs[key]
+	if prefs == nil {
+		prefs = &teleportPrefs{}
+	}
+	change(prefs)
+	if !prefs.OptOut && len(prefs.Blocked) == 0 {
+		delete(c.prefs, key)
+	} else {
+		c.prefs[key] = prefs
+	}
+	if err := c.saveLocked(); err != nil {
+		log.Printf("teleport prefs save error: %v", err)
+	}
+}
+
+// saveLocked writes preferences atomically (temp file + rename), like the strike ledger.
+func (c *teleportConsent) saveLocked() error {
+	if c.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(c.prefs)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".teleport-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+func containsFold(list []string, name string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestTeleport asks the target for permission instead of teleporting right away.
+// The returned text is the tool output, so the model tells the requester to wait.
+func requestTeleport(ctx context.Context, cfg Config, from, to string) (string, error) {
+	req, err := cfg.Consent.Request(from, to, time.Now())
+	if err != nil {
+		return "", err
+	}
+	ask := fmt.Sprintf("%s wants to teleport to you. Say %s yes to accept or %s no to decline.", req.From, cfg.TriggerWord, cfg.TriggerWord)
+	if err := tellPlayer(ctx, cfg, req.To, ask); err != nil {
+		cfg.Consent.Answer(req.To, time.Now())
+		return "", err
+	}
+	log.Printf("[TELEPORT] %s asked to visit %s", req.From, req.To)
+	return fmt.Sprintf("Asked %s for permission. %s will be teleported once %s says yes (the request expires in %s).", req.To, req.From, req.To, cfg.TeleportTimeout), nil
+}
+
+// expireTeleportRequests tells requesters whose request timed out. It runs from the main
+// loop's queue ticker, so the messages go through the worker pool.
+func expireTeleportRequests(ctx context.Context, cfg Config, pool *workerPool, now time.Time) {
+	for _, req := range cfg.Consent.Expire(now) {
+		req := req
+		log.Printf("[TELEPORT] request from %s to %s expired", req.From, req.To)
+		pool.Submit(ctx, req.From, func(ctx context.Context) {
+			msg := fmt.Sprintf("%s didn't answer your teleport request this time. Maybe ask them in chat!", req.To)
+			if err := tellPlayer(ctx, cfg, req.From, msg); err != nil {
+				log.Printf("teleport expiry notice error: %v", err)
+			}
+		})
+	}
+}
+
+// runTeleportYes answers the caller's pending request with a yes and teleports the
+// requester, provided they are still online.
+func runTeleportYes(ctx context.Context, cfg Config, evt ChatEvent, _ []string) string {
+	req, ok := cfg.Consent.Answer(evt.Player, time.Now())
+	if !ok {
+		return "You don't have any teleport requests waiting."
+	}
+	from, err := cfg.Roster.Resolve(req.From)
+	if err == nil {
+		_, err = teleportPlayer(ctx, cfg, from, evt.Player)
+	}
+	if err != nil {
+		log.Printf("[TELEPORT] accepted request from %s failed: %v", req.From, err)
+		return fmt.Sprintf("Couldn't bring %s over: %v", req.From, err)
+	}
+	log.Printf("[TELEPORT] %s accepted %s", evt.Player, from)
+	if err := tellPlayer(ctx, cfg, from, fmt.Sprintf("%s said yes - have fun!", evt.Player)); err != nil {
+		log.Printf("teleport notice error: %v", err)
+	}
+	return fmt.Sprintf("Teleported %s to you.", from)
+}
+
+// runTeleportNo declines the caller's pending request kindly.
+func runTeleportNo(ctx context.Context, cfg Config, evt ChatEvent, _ []string) string {
+	req, ok := cfg.Consent.Answer(evt.Player, time.Now())
+	if !ok {
+		return "You don't have any teleport requests waiting."
+	}
+	log.Printf("[TELEPORT] %s declined %s", evt.Player, req.From)
+	if err := tellPlayer(ctx, cfg, req.From, fmt.Sprintf("%s can't have visitors right now.", evt.Player)); err != nil {
+		log.Printf("teleport notice error: %v", err)
+	}
+	return fmt.Sprintf("No problem, %s won't be teleported to you.", req.From)
+}
+
+// runTeleportBlock adds a player to the caller's personal block list.
+func runTeleportBlock(_ context.Context, cfg Config, evt ChatEvent, args []string) string {
+	other, err := validatePlayerName(args[0])
+	if err != nil {
+		return err.Error()
+	}
+	cfg.Consent.Block(evt.Player, other)
+	return fmt.Sprintf("%s can no longer ask to teleport to you.", other)
+}
+
+// runTeleportUnblock removes a player from the caller's block list.
+func runTeleportUnblock(_ context.Context, cfg Config, evt ChatEvent, args []string) string {
+	other, err := validatePlayerName(args[0])
+	if err != nil {
+		return err.Error()
+	}
+	cfg.Consent.Unblock(evt.Player, other)
+	return fmt.Sprintf("%s can ask to teleport to you again.", other)
+}
+
+// runTeleportOff opts the caller out of all teleport requests.
+func runTeleportOff(_ context.Context, cfg Config, evt ChatEvent, _ []string) string {
+	cfg.Consent.SetOptOut(evt.Player, true)
+	return fmt.Sprintf("Teleport requests are off. Say %s tpon to turn them back on.", cfg.TriggerWord)
+}
+
+// runTeleportOn turns teleport requests back on for the caller.
+func runTeleportOn(_ context.Context, cfg Config, evt ChatEvent, _ []string) string {
+	cfg.Consent.SetOptOut(evt.Player, false)
+	return "Teleport requests are on again."
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTeleportRequestAndAccept(t *testing.T) {
+	consent, err := newTeleportConsent(time.Minute, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	console := &fakeConsole{}
+	cfg := Config{RobotName: "Alfred", TriggerWord: "!bot", TeleportTimeout: time.Minute, Console: console, Consent: consent}
+
+	out, err := requestTeleport(context.Background(), cfg, "Steve", "Alex")
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if !strings.Contains(out, "Asked Alex for permission") {
+		t.Fatalf("tool output = %q", out)
+	}
+	if _, err := consent.Request("Sam", "Alex", time.Now()); err == nil {
+		t.Fatal("a second requester should wait while Alex decides")
+	}
+
+	reply := runTeleportYes(context.Background(), cfg, ChatEvent{Player: "Alex"}, nil)
+	if reply != "Teleported Steve to you." {
+		t.Fatalf("yes reply = %q", reply)
+	}
+	want := []string{
+		"tell Alex [Alfred] Steve wants to teleport to you. Say !bot yes to accept or !bot no to decline.",
+		"tp Steve Alex",
+		"tell Steve [Alfred] Alex said yes - have fun!",
+	}
+	if got := console.sent(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("commands = %q, want %q", got, want)
+	}
+	if reply := runTeleportYes(context.Background(), cfg, ChatEvent{Player: "Alex"}, nil); !strings.Contains(reply, "don't have any") {
+		t.Fatalf("second yes = %q, want no pending request", reply)
+	}
+}
+
+func TestTeleportDeclineTellsRequester(t *testing.T) {
+	consent, _ := newTeleportConsent(time.Minute, "")
+	console := &fakeConsole{}
+	cfg := Config{RobotName: "Alfred", Console: console, Consent: consent}
+	if _, err := consent.Request("Steve", "Alex", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if reply := runTeleportNo(context.Background(), cfg, ChatEvent{Player: "Alex"}, nil); !strings.Contains(reply, "Steve won't be teleported") {
+		t.Fatalf("no reply = %q", reply)
+	}
+	if got := console.sent(); len(got) != 1 || got[0] != "tell Steve [Alfred] Alex can't have visitors right now." {
+		t.Fatalf("commands = %q", got)
+	}
+}
+
+func TestTeleportRequestExpires(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	consent, _ := newTeleportConsent(time.Minute, "")
+	console := &fakeConsole{}
+	cfg := Config{RobotName: "Alfred", Console: console, Consent: consent}
+	start := time.Now()
+	if _, err := consent.Request("Steve", "Alex", start); err != nil {
+		t.Fatal(err)
+	}
+
+	if expired := consent.Expire(start.Add(30 * time.Second)); len(expired) != 0 {
+		t.Fatalf("expired early: %+v", expired)
+	}
+	if _, ok := consent.Answer("Alex", start.Add(2*time.Minute)); ok {
+		t.Fatal("a late yes should not accept an expired request")
+	}
+
+	if _, err := consent.Request("Steve", "Alex", start); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := consent.Request("Sam", "Alex", start.Add(2*time.Minute)); err != nil {
+		t.Fatalf("a stale request should not block new ones: %v", err)
+	}
+	pool := newWorkerPool(ctx, 1, 8, &pipelineStats{})
+	expireTeleportRequests(ctx, cfg, pool, start.Add(4*time.Minute))
+	drainLane(ctx, pool, "Sam")
+	if got := console.sent(); len(got) != 1 || !strings.HasPrefix(got[0], "tell Sam [Alfred] Alex didn't answer") {
+		t.Fatalf("commands = %q, want one expiry notice to Sam", got)
+	}
+}
+
+func TestTeleportBlockAndOptOut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "teleport_prefs.json")
+	consent, err := newTeleportConsent(time.Minute, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{TriggerWord: "!bot", Consent: consent}
+	now := time.Now()
+
+	runTeleportBlock(context.Background(), cfg, ChatEvent{Player: "Alex"}, []string{"Griefer"})
+	if _, err := consent.Request("griefer", "Alex", now); !errors.Is(err, errNoVisitors) {
+		t.Fatalf("blocked request err = %v, want errNoVisitors", err)
+	}
+	if _, err := consent.Request("Steve", "Alex", now); err != nil {
+		t.Fatalf("unblocked player refused: %v", err)
+	}
+	consent.Answer("Alex", now)
+
+	runTeleportOff(context.Background(), cfg, ChatEvent{Player: "Sam"}, nil)
+	if _, err := consent.Request("Steve", "Sam", now); !errors.Is(err, errNoVisitors) {
+		t.Fatalf("opted-out request err = %v, want errNoVisitors", err)
+	}
+
+	reloaded, err := newTeleportConsent(time.Minute, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloaded.Request("Griefer", "Alex", now); !errors.Is(err, errNoVisitors) {
+		t.Fatalf("block list not saved: %v", err)
+	}
+	if _, err := reloaded.Request("Steve", "Sam", now); !errors.Is(err, errNoVisitors) {
+		t.Fatalf("opt-out not saved: %v", err)
+	}
+
+	reloaded.Unblock("Alex", "GRIEFER")
+	reloaded.SetOptOut("Sam", false)
+	if _, err := reloaded.Request("Griefer", "Alex", now); err != nil {
+		t.Fatalf("unblocked request refused: %v", err)
+	}
+	if _, err := reloaded.Request("Steve", "Sam", now); err != nil {
+		t.Fatalf("opted-in request refused: %v", err)
+	}
+	if len(reloaded.prefs) != 0 {
+		t.Fatalf("empty preferences should be dropped, have %+v", reloaded.prefs)
+	}
+}
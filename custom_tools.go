@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// customToolNamePattern matches OpenAI-style function names.
+	customToolNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,63}$`)
+	// placeholderPattern finds {{name}} slots in command templates. Double braces keep
+	// templates readable next to single-brace NBT such as {NoAI:1b}.
+	placeholderPattern = regexp.MustCompile(`\{\{\s*([a-z_][a-z0-9_]*)\s*\}\}`)
+	// safeWordPattern is what a free-form string parameter may contain once substituted.
+	safeWordPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,48}$`)
+)
+
+// customParam is the subset of JSON schema a custom tool parameter may use. Strings
+// must be an enum, a player (format "player", or the name "player"), or a single safe
+// word; numbers may carry minimum/maximum bounds.
+type customParam struct {
+	Type        string      `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Enum        []string    `json:"enum,omitempty"`
+	Format      string      `json:"format,omitempty"`
+	Minimum     *float64    `json:"minimum,omitempty"`
+	Maximum     *float64    `json:"maximum,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+}
+
+// customTool is one tool staff defined in MCCHATBOT_CUSTOM_TOOLS: a schema the model
+// fills in, and console command templates the values are substituted into.
+type customTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Parameters  struct {
+		Properties map[string]customParam `json:"properties"`
+		Required   []string               `json:"required,omitempty"`
+	} `json:"parameters"`
+	Commands []string `json:"commands"`
+	Reply    string   `json:"reply,omitempty"`
+}
+
+// loadCustomTools reads and validates the custom tool file. Every placeholder must name
+// a declared parameter (or "speaker"), so a typo fails at startup, not mid-camp.
+//
+// 🎓 LEARNING NOTE: This turns code into data. Staff can add "summon a parrot" by
+// editing a JSON file instead of writing Go and recompiling. The validation here is
+// what keeps that power safe: the AI can only fill in the blanks staff left open.
+func loadCustomTools(path string) ([]customTool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tools []customTool
+	if err := json.Unmarshal(data, &tools); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	for _, tool := range tools {
+		if err := tool.validate(); err != nil {
+			return nil, fmt.Errorf("custom tool %q: %w", tool.Name, err)
+		}
+	}
+	return tools, nil
+}
+
+func (t customTool) validate() error {
+	if !customToolNamePattern.MatchString(t.Name) {
+		return errors.New("name must be lowercase letters, digits, or underscores")
+	}
+	if strings.TrimSpace(t.Description) == "" {
+		return errors.New("description is required so the model knows when to use it")
+	}
+	if len(t.Commands) == 0 {
+		return errors.New("at least one command is required")
+	}
+	for name, param := range t.Parameters.Properties {
+		switch param.Type {
+		case "string", "integer", "number", "boolean":
+		default:
+			return fmt.Errorf("parameter %s: type must be string, integer, number, or boolean", name)
+		}
+	}
+	for _, name := range t.Parameters.Required {
+		if _, ok := t.Parameters.Properties[name]; !ok {
+			return fmt.Errorf("required parameter %s is not declared", name)
+		}
+	}
+	for _, template := range append(append([]string{}, t.Commands...), t.Reply) {
+		for _, m := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+			if _, ok := t.Parameters.Properties[m[1]]; !ok && m[1] != "speaker" {
+				return fmt.Errorf("placeholder {{%s}} is not a declared parameter", m[1])
+			}
+		}
+	}
+	return nil
+}
+
+func (t customTool) Category() toolCategory { return toolCategoryCustom }
+func (t customTool) RequiresConsent() bool  { return false }
+
+// Definition renders the tool for the model. Extra properties are refused.
+func (t customTool) Definition(Config) ToolDefinition {
+	required, properties := t.Parameters.Required, t.Parameters.Properties
+	if required == nil {
+		required = []string{}
+	}
+	if properties == nil {
+		properties = map[string]customParam{}
+	}
+	return ToolDefinition{
+		Type: "function",
+		Function: ToolFunctionDefinition{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters: map[string]interface{}{
+				"type":                 "object",
+				"properties":           properties,
+				"required":             required,
+				"additionalProperties": false,
+			},
+		},
+	}
+}
+
+// Validate checks the arguments against the declared schema; Execute then applies the
+// finer rules (online players, safe words) while binding.
+func (t customTool) Validate(cfg Config, arguments string) error {
+	return validateToolArguments(t.Definition(cfg), arguments)
+}
+
+// Execute validates the model's arguments, substitutes them into the command templates,
+// and runs the commands in order.
+func (t customTool) Execute(ctx context.Context, cfg Config, evt ChatEvent, call ToolCall) (string, error) {
+	values, err := t.bindArguments(cfg, evt, call.Function.Arguments)
+	if err != nil {
+		return "", err
+	}
+	commands := make([]string, len(t.Commands))
+	for i, template := range t.Commands {
+		if commands[i], err = fillTemplate(template, values); err != nil {
+			return "", err
+		}
+	}
+	log.Printf("[BOT] Custom tool %s for %s", t.Name, evt.Player)
+	reply, err := runConsoleBatch(ctx, cfg, commands)
+	if err != nil {
+		return "", err
+	}
+	summary := fmt.Sprintf("Ran %s.", t.Name)
+	if filled, err := fillTemplate(t.Reply, values); err == nil && filled != "" {
+		summary = filled
+	}
+	return withConsoleReply(summary, reply), nil
+}
+
+// bindArguments decodes and checks every argument, returning the text for each slot.
+func (t customTool) bindArguments(cfg Config, evt ChatEvent, raw string) (map[string]string, error) {
+	args := make(map[string]interface{})
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &args); err != nil {
+			return nil, err
+		}
+	}
+	for name := range args {
+		if _, ok := t.Parameters.Properties[name]; !ok {
+			return nil, fmt.Errorf("unknown parameter %q", name)
+		}
+	}
+	speaker, err := validatePlayerName(evt.Player)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{"speaker": speaker}
+	names := make([]string, 0, len(t.Parameters.Properties))
+	for name := range t.Parameters.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		param := t.Parameters.Properties[name]
+		value, present := args[name]
+		if !present && param.Default != nil {
+			value, present = param.Default, true
+		}
+		if !present {
+			switch {
+			case param.isPlayer(name):
+				values[name] = speaker // Player slots default to the camper who asked
+			case containsFold(t.Parameters.Required, name):
+				return nil, fmt.Errorf("missing required parameter %q", name)
+			}
+			continue
+		}
+		text, err := param.bind(cfg, name, value)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = text
+	}
+	return values, nil
+}
+
+func (p customParam) isPlayer(name string) bool {
+	return p.Type == "string" && (p.Format == "player" || name == "player")
+}
+
+// bind checks one value against its declared type and bounds and formats it for a
+// console command.
+func (p customParam) bind(cfg Config, name string, value interface{}) (string, error) {
+	switch p.Type {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("parameter %s must be a string", name)
+		}
+		s = strings.TrimSpace(s)
+		switch {
+		case p.isPlayer(name):
+			return cfg.Roster.Resolve(s)
+		case len(p.Enum) > 0:
+			for _, allowed := range p.Enum {
+				if strings.EqualFold(allowed, s) {
+					return allowed, nil
+				}
+			}
+			return "", fmt.Errorf("parameter %s must be one of %s", name, strings.Join(p.Enum, ", "))
+		case !safeWordPattern.MatchString(s):
+			return "", fmt.Errorf("parameter %s must be a single word (letters, digits, _ . : -)", name)
+		}
+		return s, nil
+	case "boolean":
+		b, ok := value.(bool)
+		if !ok {
+			return "", fmt.Errorf("parameter %s must be true or false", name)
+		}
+		return strconv.FormatBool(b), nil
+	}
+	n, ok := value.(float64)
+	if !ok || math.IsNaN(n) || math.IsInf(n, 0) {
+		return "", fmt.Errorf("parameter %s must be a number", name)
+	}
+	if p.Type == "integer" && n != math.Trunc(n) {
+		return "", fmt.Errorf("parameter %s must be a whole number", name)
+	}
+	if (p.Minimum != nil && n < *p.Minimum) || (p.Maximum != nil && n > *p.Maximum) {
+		return "", fmt.Errorf("parameter %s is out of range", name)
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64), nil
+}
+
+// fillTemplate replaces {{name}} slots with bound values. An optional parameter the
+// model left out cannot fill a slot, so that is an error rather than an empty gap.
+func fillTemplate(template string, values map[string]string) (string, error) {
+	var missing string
+	filled := placeholderPattern.ReplaceAllStringFunc(template, func(slot string) string {
+		name := placeholderPattern.FindStringSubmatch(slot)[1]
+		value, ok := values[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("missing parameter %q", missing)
+	}
+	return filled, nil
+}
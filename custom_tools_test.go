@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testCustomTool decodes and validates one tool definition.
+func testCustomTool(t *testing.T, definition string) customTool {
+	t.Helper()
+	var tool customTool
+	if err := json.Unmarshal([]byte(definition), &tool); err != nil {
+		t.Fatal(err)
+	}
+	if err := tool.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	return tool
+}
+
+const testBannerTool = `{
+	"name": "raise_banner",
+	"description": "Raise a team banner next to a camper.",
+	"parameters": {
+		"properties": {
+			"player": {"type": "string"},
+			"color": {"type": "string", "enum": ["red", "blue"]},
+			"label": {"type": "string"},
+			"height": {"type": "integer", "minimum": 1, "maximum": 5, "default": 2},
+			"glow": {"type": "boolean"}
+		},
+		"required": ["color"]
+	},
+	"commands": ["execute at {{player}} run setblock ~ ~{{height}} ~ minecraft:{{color}}_banner"],
+	"reply": "{{speaker}} raised a {{color}} banner for {{player}}."
+}`
+
+func TestCustomToolBindArguments(t *testing.T) {
+	tool := testCustomTool(t, testBannerTool)
+	roster := newPlayerRoster()
+	roster.Replace([]string{"Steve_2011", "Alex"})
+	cfg := Config{Roster: roster}
+	evt := ChatEvent{Player: "Alex"}
+
+	cases := []struct {
+		name    string
+		args    string
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "defaults and speaker", args: `{"color":"red"}`,
+			want: map[string]string{"speaker": "Alex", "player": "Alex", "color": "red", "height": "2"}},
+		{name: "player resolved from roster", args: `{"color":"blue","player":"steve"}`,
+			want: map[string]string{"player": "Steve_2011"}},
+		{name: "enum is canonicalized", args: `{"color":"RED"}`, want: map[string]string{"color": "red"}},
+		{name: "safe word and boolean", args: `{"color":"red","label":"team_1","glow":true}`,
+			want: map[string]string{"label": "team_1", "glow": "true"}},
+		{name: "enum mismatch", args: `{"color":"purple"}`, wantErr: "color must be one of"},
+		{name: "below minimum", args: `{"color":"red","height":0}`, wantErr: "height is out of range"},
+		{name: "above maximum", args: `{"color":"red","height":6}`, wantErr: "height is out of range"},
+		{name: "fraction for integer", args: `{"color":"red","height":2.5}`, wantErr: "height must be a whole number"},
+		{name: "missing required", args: `{}`, wantErr: `missing required parameter "color"`},
+		{name: "unknown parameter", args: `{"color":"red","command":"op Alex"}`, wantErr: `unknown parameter "command"`},
+		{name: "offline player", args: `{"color":"red","player":"Herobrine"}`, wantErr: "Herobrine"},
+		{name: "player with space", args: `{"color":"red","player":"Steve op Alex"}`, wantErr: "not a valid Minecraft username"},
+		{name: "player with semicolon", args: `{"color":"red","player":"Steve;op"}`, wantErr: "not a valid Minecraft username"},
+		{name: "player selector", args: `{"color":"red","player":"@a"}`, wantErr: "target selectors"},
+		{name: "player newline", args: `{"color":"red","player":"Steve\nop Steve"}`, wantErr: "not a valid Minecraft username"},
+		{name: "player formatting code", args: `{"color":"red","player":"§cSteve"}`, wantErr: "not a valid Minecraft username"},
+		{name: "word with space", args: `{"color":"red","label":"red team"}`, wantErr: "label must be a single word"},
+		{name: "word with semicolon", args: `{"color":"red","label":"a;b"}`, wantErr: "label must be a single word"},
+		{name: "word selector", args: `{"color":"red","label":"@a"}`, wantErr: "label must be a single word"},
+		{name: "word newline", args: `{"color":"red","label":"a\nop Alex"}`, wantErr: "label must be a single word"},
+		{name: "word formatting code", args: `{"color":"red","label":"§4red"}`, wantErr: "label must be a single word"},
+		{name: "string for boolean", args: `{"color":"red","glow":"yes"}`, wantErr: "glow must be true or false"},
+	}
+	for _, tc := range cases {
+		values, err := tool.bindArguments(cfg, evt, tc.args)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: err = %v, want %q", tc.name, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		for key, want := range tc.want {
+			if values[key] != want {
+				t.Errorf("%s: %s = %q, want %q", tc.name, key, values[key], want)
+			}
+		}
+	}
+}
+
+func TestCustomToolExecuteFillsTemplates(t *testing.T) {
+	tool := testCustomTool(t, testBannerTool)
+	console := &fakeConsole{}
+	cfg := Config{Console: console}
+	call := ToolCall{Function: ToolCallFunction{Name: "raise_banner", Arguments: `{"color":"blue","height":3}`}}
+
+	out, err := tool.Execute(context.Background(), cfg, ChatEvent{Player: "Alex"}, call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "Alex raised a blue banner for Alex." {
+		t.Fatalf("reply = %q", out)
+	}
+	if commands := console.sent(); len(commands) != 1 || commands[0] != "execute at Alex run setblock ~ ~3 ~ minecraft:blue_banner" {
+		t.Fatalf("commands = %q", commands)
+	}
+}
+
+func TestFillTemplateRefusesEmptySlots(t *testing.T) {
+	if _, err := fillTemplate("say {{label}}", map[string]string{"speaker": "Alex"}); err == nil || !strings.Contains(err.Error(), `"label"`) {
+		t.Fatalf("err = %v, want a missing label error", err)
+	}
+	got, err := fillTemplate("give {{ speaker }} cookie", map[string]string{"speaker": "Alex"})
+	if err != nil || got != "give Alex cookie" {
+		t.Fatalf("fillTemplate = %q, %v", got, err)
+	}
+}
+
+func TestLoadCustomToolsRejectsBadFiles(t *testing.T) {
+	if tools, err := loadCustomTools("custom_tools.example.json"); err != nil || len(tools) == 0 {
+		t.Fatalf("example file: %d tools, %v", len(tools), err)
+	}
+	cases := map[string]string{
+		"unknown placeholder":  `[{"name":"boom","description":"x","commands":["say {{target}}"]}]`,
+		"placeholder in reply": `[{"name":"boom","description":"x","commands":["say hi"],"reply":"{{who}}"}]`,
+		"bad name":             `[{"name":"Boom Tool","description":"x","commands":["say hi"]}]`,
+		"no description":       `[{"name":"boom","description":" ","commands":["say hi"]}]`,
+		"no commands":          `[{"name":"boom","description":"x","commands":[]}]`,
+		"unsupported type":     `[{"name":"boom","description":"x","parameters":{"properties":{"p":{"type":"array"}}},"commands":["say hi"]}]`,
+		"undeclared required":  `[{"name":"boom","description":"x","parameters":{"required":["p"]},"commands":["say hi"]}]`,
+		"not json":             `[{"name":`,
+	}
+	dir := t.TempDir()
+	for name, content := range cases {
+		path := filepath.Join(dir, strings.ReplaceAll(name, " ", "_")+".json")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadCustomTools(path); err == nil {
+			t.Errorf("%s: loaded without error", name)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pipelineStats counts how events flow from the log watcher through the worker pool so
+// operators can spot backpressure (a slow model making chat pile up) in the logs.
+type pipelineStats struct {
+	eventsRead   atomic.Int64
+	channelFull  atomic.Int64
+	laneFull     atomic.Int64
+	jobsInFlight atomic.Int64
+	jobsDone     atomic.Int64
+	maxLaneDepth atomic.Int64
+}
+
+// observeLaneDepth keeps the high-water mark of any single lane's backlog.
+func (s *pipelineStats) observeLaneDepth(depth int) {
+	for {
+		current := s.maxLaneDepth.Load()
+		if int64(depth) <= current || s.maxLaneDepth.CompareAndSwap(current, int64(depth)) {
+			return
+		}
+	}
+}
+
+// reportLoop logs a metrics line every interval until ctx ends. Quiet periods with no
+// new events are skipped so idle servers do not fill the journal.
+func (s *pipelineStats) reportLoop(ctx context.Context, interval time.Duration, chatCh chan ChatEvent) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastRead int64 = -1
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			read := s.eventsRead.Load()
+			if read == lastRead && s.jobsInFlight.Load() == 0 {
+				continue
+			}
+			lastRead = read
+			log.Printf("[METRICS] events=%d chat_ch=%d/%d channel_full=%d lane_full=%d in_flight=%d done=%d max_lane_depth=%d",
+				read, len(chatCh), cap(chatCh), s.channelFull.Load(), s.laneFull.Load(),
+				s.jobsInFlight.Load(), s.jobsDone.Load(), s.maxLaneDepth.Load())
+		}
+	}
+}
+
+// workerPool runs LLM work concurrently across a fixed set of lanes. Each player is
+// hashed to one lane and every lane is a single goroutine, so one camper's replies are
+// always produced in the order their messages arrived while different campers proceed
+// in parallel.
+//
+// 🎓 LEARNING NOTE: This is the "worker pool" pattern. Instead of one cashier serving a
+// long line, we open several checkout lanes - but you always use the same lane, so your
+// own items never get mixed up!
+type workerPool struct {
+	lanes []chan func(context.Context)
+	stats *pipelineStats
+	wg    sync.WaitGroup
+}
+
+// newWorkerPool starts workers goroutines, each draining a lane with depth buffered jobs.
+func newWorkerPool(ctx context.Context, workers, depth int, stats *pipelineStats) *workerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	if depth < 1 {
+		depth = 1
+	}
+	p := &workerPool{lanes: make([]chan func(context.Context), workers), stats: stats}
+	for i := range p.lanes {
+		lane := make(chan func(context.Context), depth)
+		p.lanes[i] = lane
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-lane:
+					stats.jobsInFlight.Add(1)
+					job(ctx)
+					stats.jobsInFlight.Add(-1)
+					stats.jobsDone.Add(1)
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// Submit queues job on the lane owned by key (normally the player name). When that lane
+// is full it blocks, recording the stall, which in turn slows the main loop's reads of
+// chatCh - backpressure instead of silently dropping campers' messages.
+func (p *workerPool) Submit(ctx context.Context, key string, job func(context.Context)) {
+	lane := p.lanes[laneIndex(key, len(p.lanes))]
+	p.stats.observeLaneDepth(len(lane) + 1)
+	select {
+	case lane <- job:
+		return
+	default:
+		p.stats.laneFull.Add(1)
+	}
+	select {
+	case lane <- job:
+	case <-ctx.Done():
+	}
+}
+
+// Wait blocks until every worker has exited after ctx cancellation.
+func (p *workerPool) Wait() {
+	p.wg.Wait()
+}
+
+// laneIndex hashes the case-folded key so "Steve" and "steve" share a lane.
+func laneIndex(key string, lanes int) int {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(key)))
+	return int(h.Sum32() % uint32(lanes))
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolKeepsPlayerOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := newWorkerPool(ctx, 4, 2, &pipelineStats{})
+
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+	players := []string{"Steve", "Alex", "Notch", "Jeb_", "Dinnerbone"}
+	spellings := map[string][]string{"Steve": {"Steve", "steve", "STEVE"}}
+	const jobs = 40
+	for i := 0; i < jobs; i++ {
+		for _, player := range players {
+			key := player
+			if alt := spellings[player]; alt != nil {
+				key = alt[i%len(alt)]
+			}
+			player, i := player, i
+			pool.Submit(ctx, key, func(context.Context) {
+				if i%7 == 0 {
+					time.Sleep(time.Millisecond) // let other lanes overtake this one
+				}
+				mu.Lock()
+				seen[player] = append(seen[player], i)
+				mu.Unlock()
+			})
+		}
+	}
+	for _, player := range players {
+		drainLane(ctx, pool, player)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, player := range players {
+		got := seen[player]
+		if len(got) != jobs {
+			t.Fatalf("%s ran %d jobs, want %d", player, len(got), jobs)
+		}
+		for i, n := range got {
+			if n != i {
+				t.Fatalf("%s's jobs ran as %v, want submission order", player, got)
+			}
+		}
+	}
+}
+
+// lanePair finds two players hashed to different lanes.
+func lanePair(t *testing.T, lanes int) (string, string) {
+	t.Helper()
+	first := "Steve"
+	for i := 0; i < 100; i++ {
+		other := fmt.Sprintf("Alex%d", i)
+		if laneIndex(other, lanes) != laneIndex(first, lanes) {
+			return first, other
+		}
+	}
+	t.Fatal("no two players on different lanes")
+	return "", ""
+}
+
+func TestWorkerPoolRunsLanesInParallel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := newWorkerPool(ctx, 4, 2, &pipelineStats{})
+	slow, fast := lanePair(t, 4)
+
+	release := make(chan struct{})
+	pool.Submit(ctx, slow, func(context.Context) { <-release })
+	done := make(chan struct{})
+	go func() {
+		drainLane(ctx, pool, fast)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("%s waited behind %s's stuck job on another lane", fast, slow)
+	}
+	close(release)
+	drainLane(ctx, pool, slow)
+}
+
+func TestWorkerPoolBackpressure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stats := &pipelineStats{}
+	pool := newWorkerPool(ctx, 1, 1, stats)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit(ctx, "Steve", func(context.Context) {
+		close(started)
+		<-release
+	})
+	<-started
+	pool.Submit(ctx, "Steve", func(context.Context) {}) // fills the one-slot lane
+
+	submitted := make(chan struct{})
+	go func() {
+		pool.Submit(ctx, "Alex", func(context.Context) {})
+		close(submitted)
+	}()
+	select {
+	case <-submitted:
+		t.Fatal("Submit on a full lane returned instead of waiting")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if stats.laneFull.Load() != 1 || stats.maxLaneDepth.Load() != 2 {
+		t.Fatalf("lane_full=%d max_lane_depth=%d, want 1 and 2", stats.laneFull.Load(), stats.maxLaneDepth.Load())
+	}
+	close(release)
+	select {
+	case <-submitted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Submit stayed blocked after the lane drained")
+	}
+	drainLane(ctx, pool, "Steve")
+	if stats.jobsDone.Load() != 4 || stats.jobsInFlight.Load() != 0 {
+		t.Fatalf("done=%d in_flight=%d, want 4 and 0", stats.jobsDone.Load(), stats.jobsInFlight.Load())
+	}
+
+	// After shutdown a blocked Submit gives up instead of hanging the main loop.
+	block := make(chan struct{})
+	pool.Submit(ctx, "Steve", func(context.Context) { <-block })
+	pool.Submit(ctx, "Steve", func(context.Context) {})
+	cancel()
+	pool.Submit(ctx, "Steve", func(context.Context) {})
+	close(block)
+	pool.Wait()
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Incident priorities. Self-harm and grooming are "high"; other staff alerts are "normal".
+const (
+	incidentPriorityHigh   = "high"
+	incidentPriorityNormal = "normal"
+)
+
+// incident is the record handed to staff when chat needs a human, including the lines
+// that led up to it so nobody has to dig through server logs first.
+type incident struct {
+	ID       string   `json:"id"`
+	Time     string   `json:"time"`
+	Priority string   `json:"priority"`
+	Player   string   `json:"player"`
+	Category string   `json:"category"`
+	Match    string   `json:"match"`
+	Message  string   `json:"message"`
+	Line     int64    `json:"line,omitempty"` // Transcript line ID, for `mcchatbot incidents`
+	Context  []string `json:"context,omitempty"`
+}
+
+// incidentNotifier delivers an incident to humans. Production code fans out to in-game
+// staff, an incident file, and a webhook; anything satisfying the interface (a fake that
+// just records calls, say) can stand in for them.
+type incidentNotifier interface {
+	Notify(ctx context.Context, inc incident) error
+}
+
+// multiNotifier sends to every notifier and reports all failures together, so a broken
+// webhook never stops the incident file from being written.
+type multiNotifier []incidentNotifier
+
+func (m multiNotifier) Notify(ctx context.Context, inc incident) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, inc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newIncidentNotifier wires up the notifiers that have configuration, returning nil
+// when there are none. It needs cfg.Console, so loadConfig calls it last.
+func newIncidentNotifier(cfg Config) incidentNotifier {
+	var notifiers multiNotifier
+	if len(cfg.StaffNames) > 0 && cfg.Console != nil {
+		notifiers = append(notifiers, staffTellNotifier{staff: cfg.StaffNames, robotName: cfg.RobotName, console: cfg.Console})
+	}
+	if cfg.IncidentLog != "" {
+		notifiers = append(notifiers, &incidentFileNotifier{path: cfg.IncidentLog})
+	}
+	if cfg.IncidentWebhook != "" {
+		notifiers = append(notifiers, webhookNotifier{url: cfg.IncidentWebhook, client: &http.Client{Timeout: 10 * time.Second}})
+	}
+	if len(notifiers) == 0 {
+		return nil
+	}
+	return notifiers
+}
+
+// staffTellNotifier whispers the incident to each staff member with /tell. Vanilla
+// answers "No player was found" for anyone offline, which runConsoleCommand turns into
+// an error; those are skipped, and only nobody being reached counts as a failure.
+type staffTellNotifier struct {
+	staff     []string
+	robotName string
+	console   CommandTransport
+}
+
+func (n staffTellNotifier) Notify(ctx context.Context, inc incident) error {
+	msg := fmt.Sprintf("[%s] %s-priority %s alert from %s: %s", n.robotName, inc.Priority, inc.Category, inc.Player, inc.Message)
+	msg = sanitizeChatText(msg)
+	reached := 0
+	for _, name := range n.staff {
+		reply, err := n.console.Run(ctx, fmt.Sprintf("tell %s %s", name, msg))
+		if err == nil {
+			err = consoleReplyError(reply)
+		}
+		if err != nil {
+			log.Printf("staff %s not reached: %v", name, err)
+			continue
+		}
+		reached++
+	}
+	if reached == 0 {
+		return fmt.Errorf("staff notifier: none of %d staff members are online", len(n.staff))
+	}
+	return nil
+}
+
+// incidentFileNotifier appends incidents as JSON lines to a file kept apart from the
+// everyday interaction log, so high-priority records are easy to find.
+type incidentFileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (n *incidentFileNotifier) Notify(_ context.Context, inc incident) error {
+	data, err := json.Marshal(inc)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	file, err := os.OpenFile(n.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// webhookNotifier POSTs the incident as JSON (Slack/Discord relays, a pager, etc.).
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (n webhookNotifier) Notify(ctx context.Context, inc incident) error {
+	payload, err := json.Marshal(inc)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("incident webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("incident webhook: %s - %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// recentChat keeps the last few chat lines so incidents can show what led up to them.
+// The main loop adds lines while workers take snapshots, hence the mutex.
+type recentChat struct {
+	mu    sync.Mutex
+	lines []string
+	size  int
+}
+
+func newRecentChat(size int) *recentChat {
+	return &recentChat{size: size}
+}
+
+// Add remembers one chat line. It is a no-op on a nil receiver or zero size.
+func (r *recentChat) Add(evt ChatEvent) {
+	if r == nil || r.size <= 0 {
+		return
+	}
+	t := evt.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, fmt.Sprintf("%s <%s> %s", t.Format("15:04:05"), evt.Player, evt.Text))
+	if len(r.lines) > r.size {
+		r.lines = r.lines[len(r.lines)-r.size:]
+	}
+}
+
+// Snapshot returns a copy of the remembered lines, oldest first.
+func (r *recentChat) Snapshot() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.lines...)
+}
+
+// supportMessage is the fixed line posted for an escalated category. It is deliberately
+// not produced by the LLM: a sad camper must never get a joke by accident. Other
+// categories an admin chooses to escalate get no public message.
+func supportMessage(cfg Config, category moderationCategory, player string) string {
+	var template string
+	switch category {
+	case categorySelfHarm:
+		template = cfg.SelfHarmMessage
+	case categoryGrooming:
+		template = cfg.GroomingMessage
+	}
+	return strings.ReplaceAll(template, "{player}", player)
+}
+
+// escalateToStaff handles the categories that need a human: it posts the gentle support
+// line, then notifies staff with the recent chat as context. Nothing else happens - no
+// lightning, no LLM reply.
+func escalateToStaff(ctx context.Context, cfg Config, evt ChatEvent, verdict moderationVerdict) []ToolInvocation {
+	var logs []ToolInvocation
+	if msg := supportMessage(cfg, verdict.Category, evt.Player); msg != "" {
+		inv := ToolInvocation{Name: "moderation_support_message", Output: msg}
+		if err := announce(ctx, cfg, msg); err != nil {
+			log.Printf("send error: %v", err)
+			inv.Error = err.Error()
+		}
+		logs = append(logs, inv)
+	}
+	return append(logs, notifyStaff(ctx, cfg, evt, verdict, incidentPriorityHigh))
+}
+
+// notifyStaff builds the incident record and hands it to the configured notifiers.
+func notifyStaff(ctx context.Context, cfg Config, evt ChatEvent, verdict moderationVerdict, priority string) ToolInvocation {
+	now := time.Now()
+	inc := incident{
+		ID:       fmt.Sprintf("%s-%s", now.UTC().Format("20060102T150405.000"), strings.ToLower(evt.Player)),
+		Time:     now.Format(time.RFC3339),
+		Priority: priority,
+		Player:   evt.Player,
+		Category: string(verdict.Category),
+		Match:    verdict.Match,
+		Message:  evt.Text,
+		Line:     evt.Line,
+		Context:  cfg.RecentChat.Snapshot(),
+	}
+	log.Printf("[STAFF ALERT] %s %s (%s): %s", priority, evt.Player, verdict.Category, evt.Text)
+	inv := ToolInvocation{
+		Name:      "moderation_staff_alert",
+		Arguments: fmt.Sprintf(`{"incident":%q,"priority":%q}`, inc.ID, priority),
+		Output:    "Staff notified.",
+	}
+	if cfg.Incidents == nil {
+		inv.Output = "No incident notifier configured; alert logged only."
+		return inv
+	}
+	if err := cfg.Incidents.Notify(ctx, inc); err != nil {
+		log.Printf("incident notify error: %v", err)
+		inv.Error = err.Error()
+	}
+	return inv
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeConsole records every command instead of reaching a server. reply, when set,
+// supplies the server's answer for a command.
+type fakeConsole struct {
+	mu       sync.Mutex
+	commands []string
+	reply    func(command string) string
+}
+
+func (c *fakeConsole) Run(ctx context.Context, command string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commands = append(c.commands, command)
+	if c.reply != nil {
+		return c.reply(command), nil
+	}
+	return "", nil
+}
+
+func (c *fakeConsole) Close() error { return nil }
+
+func (c *fakeConsole) sent() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.commands...)
+}
+
+// fakeNotifier keeps the incidents it is handed.
+type fakeNotifier struct {
+	incidents []incident
+	err       error
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, inc incident) error {
+	n.incidents = append(n.incidents, inc)
+	return n.err
+}
+
+func TestEscalateToStaffPostsSupportAndNotifies(t *testing.T) {
+	console := &fakeConsole{}
+	notifier := &fakeNotifier{}
+	recent := newRecentChat(5)
+	recent.Add(ChatEvent{Player: "Alex", Text: "nobody picked me for the team"})
+	cfg := Config{
+		RobotName:       "Alfred",
+		Console:         console,
+		Incidents:       notifier,
+		RecentChat:      recent,
+		SelfHarmMessage: "{player}, you matter. A counselor is on the way to chat with you.",
+	}
+	evt := ChatEvent{Kind: EventChat, Player: "Alex", Text: "i want to die", Line: 42}
+	verdict, ok := testModeration(t).Evaluate(evt.Text)
+	if !ok || !verdict.Has(actionEscalate) {
+		t.Fatalf("verdict = %+v, want an escalation", verdict)
+	}
+
+	logs := applyModerationActions(context.Background(), cfg, evt, verdict)
+
+	commands := console.sent()
+	if len(commands) != 1 || commands[0] != "say [Alfred] Alex, you matter. A counselor is on the way to chat with you." {
+		t.Fatalf("console commands = %q, want only the support message", commands)
+	}
+	if len(notifier.incidents) != 1 {
+		t.Fatalf("notifier got %d incidents, want 1", len(notifier.incidents))
+	}
+	inc := notifier.incidents[0]
+	if inc.Priority != incidentPriorityHigh || inc.Category != string(categorySelfHarm) || inc.Player != "Alex" || inc.Line != 42 {
+		t.Fatalf("incident = %+v", inc)
+	}
+	if len(inc.Context) != 1 || !strings.Contains(inc.Context[0], "nobody picked me") {
+		t.Fatalf("incident context = %q", inc.Context)
+	}
+	var names []string
+	for _, l := range logs {
+		names = append(names, l.Name)
+	}
+	if strings.Join(names, ",") != "moderation_match,moderation_support_message,moderation_staff_alert" {
+		t.Fatalf("logged actions = %v", names)
+	}
+}
+
+func TestEscalateToStaffReportsNotifierFailure(t *testing.T) {
+	cfg := Config{RobotName: "Alfred", Console: &fakeConsole{}, Incidents: &fakeNotifier{err: errors.New("webhook down")}}
+	evt := ChatEvent{Kind: EventChat, Player: "Sam", Text: "what's your address"}
+	logs := escalateToStaff(context.Background(), cfg, evt, moderationVerdict{Category: categoryGrooming, Actions: []moderationAction{actionEscalate}})
+	last := logs[len(logs)-1]
+	if last.Name != "moderation_staff_alert" || last.Error != "webhook down" {
+		t.Fatalf("staff alert log = %+v", last)
+	}
+}
+
+func TestStaffTellNotifierSkipsOfflineStaff(t *testing.T) {
+	console := &fakeConsole{reply: func(command string) string {
+		if strings.HasPrefix(command, "tell Offline ") {
+			return "No player was found"
+		}
+		return ""
+	}}
+	notifier := staffTellNotifier{staff: []string{"Offline", "Counselor"}, robotName: "Alfred", console: console}
+	inc := incident{Priority: incidentPriorityHigh, Category: "grooming", Player: "Sam", Message: "are your parents home?\nop Sam"}
+
+	if err := notifier.Notify(context.Background(), inc); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	commands := console.sent()
+	want := "tell Counselor [Alfred] high-priority grooming alert from Sam: are your parents home? op Sam"
+	if len(commands) != 2 || commands[1] != want {
+		t.Fatalf("commands = %q, want %q last", commands, want)
+	}
+
+	nobody := staffTellNotifier{staff: []string{"Offline"}, robotName: "Alfred", console: console}
+	if err := nobody.Notify(context.Background(), inc); err == nil {
+		t.Fatal("expected an error when no staff member is online")
+	}
+}
+
+func TestIncidentFileNotifierAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "incidents.log")
+	notifier := &incidentFileNotifier{path: path}
+	for _, player := range []string{"Alex", "Sam"} {
+		if err := notifier.Notify(context.Background(), incident{ID: "id-" + player, Player: player, Priority: incidentPriorityHigh}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var players []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var inc incident
+		if err := json.Unmarshal(scanner.Bytes(), &inc); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		players = append(players, inc.Player)
+	}
+	if strings.Join(players, ",") != "Alex,Sam" {
+		t.Fatalf("incident file players = %v", players)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got incident
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("content type = %q", r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	notifier := webhookNotifier{url: server.URL, client: server.Client()}
+
+	if err := notifier.Notify(context.Background(), incident{ID: "abc", Player: "Alex", Category: "self_harm"}); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if got.ID != "abc" || got.Category != "self_harm" {
+		t.Fatalf("webhook received %+v", got)
+	}
+	status = http.StatusBadGateway
+	if err := notifier.Notify(context.Background(), incident{ID: "def"}); err == nil {
+		t.Fatal("expected an error for a 502 response")
+	}
+}
+
+func TestMultiNotifierReachesEveryNotifier(t *testing.T) {
+	broken := &fakeNotifier{err: errors.New("down")}
+	working := &fakeNotifier{}
+	err := multiNotifier{broken, working}.Notify(context.Background(), incident{ID: "x"})
+	if err == nil || len(working.incidents) != 1 {
+		t.Fatalf("err = %v, working notifier got %d incidents", err, len(working.incidents))
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// lifecycleEventPrompt turns a join, death, or advancement into an instruction for the
+// LLM. It returns false when that event kind is disabled in config or not one Alfred
+// reacts to, so the caller can stay quiet.
+//
+// 🎓 LEARNING NOTE: The player didn't say anything here - we write the prompt ourselves
+// to describe what happened, and the AI turns it into a friendly camp-counselor line.
+func lifecycleEventPrompt(cfg Config, evt ChatEvent) (string, bool) {
+	switch evt.Kind {
+	case EventJoin:
+		if !cfg.EnableJoinGreeting {
+			return "", false
+		}
+		return fmt.Sprintf("Camper %s just joined the server. Give them a short, warm welcome.", evt.Player), true
+	case EventDeath:
+		if !cfg.EnableDeathComfort {
+			return "", false
+		}
+		return fmt.Sprintf("Camper %s just died in the game (%s). Comfort them kindly and share one quick tip to stay safe next time.", evt.Player, evt.Detail), true
+	case EventAdvancement:
+		if !cfg.EnableAdvancementCheer {
+			return "", false
+		}
+		return fmt.Sprintf("Camper %s just earned the advancement [%s]. Celebrate the achievement with them!", evt.Player, evt.Detail), true
+	}
+	return "", false
+}
+
+// handleLifecycleEvent greets newcomers, comforts fallen campers, and cheers advancements.
+// It spends from the global reply budget so a wave of joins cannot flood the server;
+// reactions are skipped rather than queued because a late welcome feels odd. The LLM
+// call itself runs on the player's worker lane.
+func handleLifecycleEvent(ctx context.Context, cfg Config, gate *replyGate, pool *workerPool, evt ChatEvent) {
+	log.Printf("[EVENT] %s: %s", evt.Kind, evt.Text)
+	prompt, ok := lifecycleEventPrompt(cfg, evt)
+	if !ok {
+		return
+	}
+	if !gate.AdmitGlobal(time.Now()) {
+		log.Printf("Skipping %s reaction (rate limit) for %s", evt.Kind, evt.Player)
+		return
+	}
+	pool.Submit(ctx, evt.Player, func(ctx context.Context) {
+		reactToLifecycleEvent(ctx, cfg, evt, prompt)
+	})
+}
+
+// reactToLifecycleEvent asks the LLM for the reaction, posts it, and logs it.
+func reactToLifecycleEvent(ctx context.Context, cfg Config, evt ChatEvent, prompt string) {
+	resp, toolLogs, err := callLLM(ctx, cfg, evt, prompt)
+	if err != nil {
+		log.Printf("LLM error: %v", err)
+		return
+	}
+	log.Printf("[BOT] Response: %s", resp)
+	if err := sendToMinecraft(ctx, cfg, resp); err != nil {
+		log.Printf("send error: %v", err)
+		return
+	}
+	if err := logInteraction(cfg, evt, resp, toolLogs); err != nil {
+		log.Printf("log error: %v", err)
+	}
+}
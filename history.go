@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// channelThreadKey names the shared thread that remembers what everyone asked recently.
+const channelThreadKey = "channel:global"
+
+// conversationExchange is one complete turn: the camper's message, any assistant tool
+// calls with their tool results, and Alfred's final reply.
+type conversationExchange struct {
+	Player   string    `json:"player"`
+	Time     time.Time `json:"time"`
+	Messages []Message `json:"messages"`
+}
+
+// conversationThread holds the most recent exchanges for one player or channel.
+type conversationThread struct {
+	Exchanges []conversationExchange `json:"exchanges"`
+	LastSeen  time.Time              `json:"last_seen"`
+}
+
+// conversationMemory is a bounded, idle-expiring store of recent exchanges keyed by
+// player and by channel. It lets callLLM remind the model what a camper just asked
+// without letting any one conversation grow without limit.
+//
+// 🎓 LEARNING NOTE: LLMs don't remember anything between requests! "Memory" is just us
+// re-sending the last few messages every time, trimmed so we stay within a token budget.
+type conversationMemory struct {
+	mu              sync.Mutex
+	maxExchanges    int
+	channelExchange int
+	tokenBudget     int
+	idleExpiry      time.Duration
+	path            string
+	threads         map[string]*conversationThread
+}
+
+// newConversationMemory builds the store and, when a path is configured, reloads the
+// threads saved by a previous run. A missing file is fine; a corrupt one is reported.
+func newConversationMemory(maxExchanges, channelExchanges, tokenBudget int, idleExpiry time.Duration, path string) (*conversationMemory, error) {
+	m := &conversationMemory{
+		maxExchanges:    maxExchanges,
+		channelExchange: channelExchanges,
+		tokenBudget:     tokenBudget,
+		idleExpiry:      idleExpiry,
+		path:            path,
+		threads:         make(map[string]*conversationThread),
+	}
+	if path == "" {
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m.threads); err != nil {
+		return nil, err
+	}
+	m.expireLocked(time.Now())
+	return m, nil
+}
+
+// Recall returns prior messages for the player, blended with other campers' recent
+// channel exchanges, oldest first and trimmed to the token budget.
+func (m *conversationMemory) Recall(player string, now time.Time) []Message {
+	if m == nil || m.maxExchanges <= 0 {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expireLocked(now)
+
+	var exchanges []conversationExchange
+	if thread, ok := m.threads[playerThreadKey(player)]; ok {
+		exchanges = append(exchanges, thread.Exchanges...)
+	}
+	if thread, ok := m.threads[channelThreadKey]; ok && m.channelExchange > 0 {
+		var others []conversationExchange
+		for _, ex := range thread.Exchanges {
+			if !strings.EqualFold(ex.Player, player) {
+				others = append(others, ex)
+			}
+		}
+		if len(others) > m.channelExchange {
+			others = others[len(others)-m.channelExchange:]
+		}
+		exchanges = append(exchanges, others...)
+	}
+	sort.SliceStable(exchanges, func(i, j int) bool { return exchanges[i].Time.Before(exchanges[j].Time) })
+
+	// Drop the oldest exchanges until the remainder fits the budget. Whole exchanges are
+	// removed so tool results are never separated from the call that produced them.
+	for len(exchanges) > 0 && m.tokenBudget > 0 && exchangeTokens(exchanges) > m.tokenBudget {
+		exchanges = exchanges[1:]
+	}
+	var messages []Message
+	for _, ex := range exchanges {
+		messages = append(messages, ex.Messages...)
+	}
+	return messages
+}
+
+// Record stores a finished exchange in both the player's and the channel's thread and
+// persists the store when a file is configured.
+func (m *conversationMemory) Record(player string, messages []Message, now time.Time) {
+	if m == nil || m.maxExchanges <= 0 || len(messages) == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ex := conversationExchange{Player: player, Time: now, Messages: messages}
+	m.appendLocked(playerThreadKey(player), ex, m.maxExchanges)
+	if m.channelExchange > 0 {
+		m.appendLocked(channelThreadKey, ex, m.channelExchange+m.maxExchanges)
+	}
+	if err := m.saveLocked(); err != nil {
+		log.Printf("memory save error: %v", err)
+	}
+}
+
+func (m *conversationMemory) appendLocked(key string, ex conversationExchange, limit int) {
+	thread, ok := m.threads[key]
+	if !ok {
+		thread = &conversationThread{}
+		m.threads[key] = thread
+	}
+	thread.Exchanges = append(thread.Exchanges, ex)
+	if len(thread.Exchanges) > limit {
+		thread.Exchanges = thread.Exchanges[len(thread.Exchanges)-limit:]
+	}
+	thread.LastSeen = ex.Time
+}
+
+// expireLocked forgets threads that have been idle longer than the configured window.
+func (m *conversationMemory) expireLocked(now time.Time) {
+	if m.idleExpiry <= 0 {
+		return
+	}
+	for key, thread := range m.threads {
+		if now.Sub(thread.LastSeen) > m.idleExpiry {
+			delete(m.threads, key)
+		}
+	}
+}
+
+// saveLocked writes the store atomically (temp file + rename) so a crash mid-write never
+// leaves a truncated history behind.
+func (m *conversationMemory) saveLocked() error {
+	if m.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(m.threads)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), ".memory-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), m.path)
+}
+
+func playerThreadKey(player string) string {
+	return "player:" + strings.ToLower(strings.TrimSpace(player))
+}
+
+// exchangeTokens estimates token usage with the common four-characters-per-token rule,
+// plus a small per-message overhead for roles and tool metadata.
+func exchangeTokens(exchanges []conversationExchange) int {
+	total := 0
+	for _, ex := range exchanges {
+		for _, msg := range ex.Messages {
+			total += 4 + len(msg.Content)/4
+			for _, call := range msg.ToolCalls {
+				total += 4 + (len(call.Function.Name)+len(call.Function.Arguments))/4
+			}
+		}
+	}
+	return total
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// runHistoryCommand implements `mcchatbot history`: search past interactions by player,
+// date range, tool, or moderation category, and print them or export them as CSV for a
+// parent or educator review. It reads the SQLite store when MCCHATBOT_HISTORY_DB (or -db)
+// is set and otherwise scans the JSONL interaction log, including rotated segments.
+//
+// 🎓 LEARNING NOTE: A "subcommand" lets one program do more than one job, like
+// `git commit` and `git log`. Running `mcchatbot` starts Alfred; `mcchatbot history`
+// just answers a question about the past and exits.
+func runHistoryCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	opts := addHistoryFlags(flags, stderr, 50)
+	flags.StringVar(&opts.filter.Tool, "tool", "", "only interactions that used this tool, e.g. teleport_player or moderation_kick")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	records, code := opts.load(stderr)
+	if code != 0 {
+		return code
+	}
+	err := opts.export(stdout, stderr, len(records), func(w io.Writer) error {
+		return writeHistoryCSV(w, records)
+	}, func(w io.Writer) {
+		printHistory(w, records)
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "history: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// historyOptions holds the flags `history` and `incidents` share.
+type historyOptions struct {
+	filter          historyFilter
+	since, until    string
+	dbPath, logPath string
+	csvPath         string
+}
+
+// addHistoryFlags registers the shared filter, source, and export flags.
+func addHistoryFlags(flags *flag.FlagSet, stderr io.Writer, limit int) *historyOptions {
+	opts := &historyOptions{}
+	flags.SetOutput(stderr)
+	flags.StringVar(&opts.filter.Player, "player", "", "only this player (case-insensitive)")
+	flags.StringVar(&opts.since, "since", "", "start date, YYYY-MM-DD or RFC 3339 (inclusive)")
+	flags.StringVar(&opts.until, "until", "", "end date, YYYY-MM-DD (whole day included) or RFC 3339")
+	flags.StringVar(&opts.filter.Category, "category", "", "only moderation hits in this category, e.g. bullying")
+	flags.IntVar(&opts.filter.Limit, "limit", limit, "most recent matches to show (0 for all)")
+	flags.StringVar(&opts.csvPath, "csv", "", "write CSV to this file (- for stdout) instead of a readable list")
+	flags.StringVar(&opts.dbPath, "db", strings.TrimSpace(os.Getenv("MCCHATBOT_HISTORY_DB")), "SQLite history database")
+	flags.StringVar(&opts.logPath, "log", envOr("MCCHATBOT_RESPONSE_LOG", defaultResponseLog), "JSONL interaction log, used when no database is set")
+	return opts
+}
+
+// load parses the date flags and runs the query against the database or the JSONL log.
+// A non-zero code means the error was already reported.
+func (o *historyOptions) load(stderr io.Writer) ([]interactionRecord, int) {
+	var err error
+	if o.filter.Since, err = parseHistoryTime(o.since, false); err != nil {
+		fmt.Fprintf(stderr, "history: -since: %v\n", err)
+		return nil, 2
+	}
+	if o.filter.Until, err = parseHistoryTime(o.until, true); err != nil {
+		fmt.Fprintf(stderr, "history: -until: %v\n", err)
+		return nil, 2
+	}
+	var records []interactionRecord
+	if o.dbPath != "" {
+		var store *historyStore
+		if store, err = openHistoryStore(o.dbPath); err != nil {
+			fmt.Fprintf(stderr, "history: %v\n", err)
+			return nil, 1
+		}
+		defer store.Close()
+		records, err = store.Query(o.filter)
+	} else {
+		records, err = queryInteractionLog(o.logPath, o.filter)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "history: %v\n", err)
+		return nil, 1
+	}
+	return records, 0
+}
+
+// export writes CSV to the -csv destination, or the readable form to stdout.
+func (o *historyOptions) export(stdout, stderr io.Writer, count int, writeCSV func(io.Writer) error, print func(io.Writer)) error {
+	switch o.csvPath {
+	case "":
+		print(stdout)
+		return nil
+	case "-":
+		return writeCSV(stdout)
+	}
+	file, err := os.Create(o.csvPath)
+	if err != nil {
+		return err
+	}
+	err = writeCSV(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		fmt.Fprintf(stderr, "Wrote %d records to %s\n", count, o.csvPath)
+	}
+	return err
+}
+
+// parseHistoryTime reads YYYY-MM-DD (local midnight) or RFC 3339. For an end date given
+// as a plain day, the whole day is included.
+func parseHistoryTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// printHistory writes one readable block per interaction.
+func printHistory(w io.Writer, records []interactionRecord) {
+	if len(records) == 0 {
+		fmt.Fprintln(w, "No matching interactions.")
+		return
+	}
+	for _, r := range records {
+		who := r.Player
+		if r.Event != "" {
+			who += " (" + r.Event + ")"
+		}
+		fmt.Fprintf(w, "%s  %s: %s\n", r.Time, who, r.Question)
+		if r.Response != "" {
+			fmt.Fprintf(w, "    Alfred: %s\n", r.Response)
+		}
+		for _, tool := range r.Tools {
+			result := tool.Output
+			if tool.Error != "" {
+				result = "error: " + tool.Error
+			}
+			fmt.Fprintf(w, "    [%s] %s %s\n", tool.Name, tool.Arguments, result)
+		}
+	}
+}
+
+// writeHistoryCSV exports one row per interaction, with tools and moderation categories
+// flattened into semicolon-separated columns so the file opens cleanly in a spreadsheet.
+func writeHistoryCSV(w io.Writer, records []interactionRecord) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"time", "event", "player", "question", "response", "tools", "moderation_categories"}); err != nil {
+		return err
+	}
+	for _, r := range records {
+		var tools, categories []string
+		for _, tool := range r.Tools {
+			tools = append(tools, tool.Name)
+		}
+		for _, event := range r.moderationEvents() {
+			if !containsFold(categories, event.Category) {
+				categories = append(categories, event.Category)
+			}
+		}
+		row := []string{r.Time, r.Event, r.Player, csvSafe(r.Question), csvSafe(r.Response), strings.Join(tools, ";"), strings.Join(categories, ";")}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// csvSafe stops chat text that starts like a formula (=, +, -, @) from being run by a
+// spreadsheet when the export is opened.
+func csvSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testExchange is a question and answer whose contents are 40 characters each, so the
+// pair costs 2*(4+10) = 28 estimated tokens.
+func testExchange(label string) []Message {
+	pad := func(s string) string { return s + strings.Repeat(".", 40-len(s)) }
+	return []Message{
+		{Role: "user", Content: pad("question " + label)},
+		{Role: "assistant", Content: pad("answer " + label)},
+	}
+}
+
+func recalledContents(messages []Message) []string {
+	var out []string
+	for _, msg := range messages {
+		out = append(out, strings.TrimRight(msg.Content, "."))
+	}
+	return out
+}
+
+func TestConversationMemoryTrimsToTokenBudget(t *testing.T) {
+	memory, err := newConversationMemory(10, 0, 60, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	for i, label := range []string{"one", "two", "three"} {
+		memory.Record("Steve", testExchange(label), start.Add(time.Duration(i)*time.Minute))
+	}
+
+	got := recalledContents(memory.Recall("Steve", start.Add(5*time.Minute)))
+	want := []string{"question two", "answer two", "question three", "answer three"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("recall = %q, want the two newest exchanges (56 of 60 tokens)", got)
+	}
+}
+
+func TestConversationMemoryDropsToolExchangesWhole(t *testing.T) {
+	memory, err := newConversationMemory(10, 0, 60, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	memory.Record("Steve", []Message{
+		{Role: "user", Content: "take me home"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Function: ToolCallFunction{Name: teleportToolName, Arguments: `{"destination":"home"}`}}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "Teleported Steve home."},
+		{Role: "assistant", Content: "Welcome home!"},
+	}, start)
+	memory.Record("Steve", testExchange("one"), start.Add(time.Minute))
+	memory.Record("Steve", testExchange("two"), start.Add(2*time.Minute))
+
+	for _, msg := range memory.Recall("Steve", start.Add(3*time.Minute)) {
+		if msg.Role == "tool" || len(msg.ToolCalls) > 0 {
+			t.Fatalf("recall kept part of the trimmed tool exchange: %+v", msg)
+		}
+	}
+}
+
+func TestConversationMemoryKeepsRecentExchangesAndChannel(t *testing.T) {
+	memory, err := newConversationMemory(2, 1, 0, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	memory.Record("Steve", testExchange("s1"), start)
+	memory.Record("Alex", testExchange("a1"), start.Add(time.Minute))
+	memory.Record("Steve", testExchange("s2"), start.Add(2*time.Minute))
+	memory.Record("Alex", testExchange("a2"), start.Add(3*time.Minute))
+	memory.Record("steve", testExchange("s3"), start.Add(4*time.Minute))
+
+	got := recalledContents(memory.Recall("STEVE", start.Add(5*time.Minute)))
+	want := []string{"question s2", "answer s2", "question a2", "answer a2", "question s3", "answer s3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("recall = %q, want Steve's last two exchanges and Alex's newest, in time order", got)
+	}
+}
+
+func TestConversationMemoryExpiresIdleThreads(t *testing.T) {
+	memory, err := newConversationMemory(5, 0, 0, 10*time.Minute, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	memory.Record("Steve", testExchange("one"), start)
+	memory.Record("Alex", testExchange("one"), start)
+
+	if got := memory.Recall("Steve", start.Add(9*time.Minute)); len(got) != 2 {
+		t.Fatalf("recall within the idle window = %d messages, want 2", len(got))
+	}
+	memory.Record("Alex", testExchange("two"), start.Add(9*time.Minute))
+	if got := memory.Recall("Steve", start.Add(11*time.Minute)); len(got) != 0 {
+		t.Fatalf("recall after the idle window = %q, want nothing", recalledContents(got))
+	}
+	if got := memory.Recall("Alex", start.Add(11*time.Minute)); len(got) != 4 {
+		t.Fatalf("Alex's recent exchange refreshed the thread, got %d messages, want 4", len(got))
+	}
+}
+
+func TestConversationMemorySaveAndReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "memory.json")
+	memory, err := newConversationMemory(5, 0, 0, time.Hour, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tool := []Message{
+		{Role: "user", Content: "fireworks please"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Type: "function", Function: ToolCallFunction{Name: fireworkToolName, Arguments: `{}`}}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "Launched a firework for Steve."},
+		{Role: "assistant", Content: "Boom!"},
+	}
+	memory.Record("Steve", testExchange("one"), now.Add(-2*time.Minute))
+	memory.Record("Steve", tool, now.Add(-time.Minute))
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "memory.json" {
+		t.Fatalf("directory holds %v, want only memory.json after the temp file was renamed", entries)
+	}
+
+	reloaded, err := newConversationMemory(5, 0, 0, time.Hour, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(testExchange("one"), tool...)
+	if got := reloaded.Recall("Steve", now); !reflect.DeepEqual(got, want) {
+		t.Fatalf("reloaded recall = %+v, want %+v", got, want)
+	}
+
+	// A reload after the idle window starts empty.
+	stale, err := newConversationMemory(5, 0, 0, 30*time.Second, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stale.Recall("Steve", now); len(got) != 0 {
+		t.Fatalf("stale reload recalled %d messages, want none", len(got))
+	}
+}
+
+func TestConversationMemoryReportsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.json")
+	if err := os.WriteFile(path, []byte(`{"player:steve": {"exchanges": [`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newConversationMemory(5, 0, 0, time.Hour, path); err == nil {
+		t.Fatal("corrupt memory file loaded without error")
+	}
+	if _, err := newConversationMemory(5, 0, 0, time.Hour, filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Fatalf("missing memory file: %v", err)
+	}
+}
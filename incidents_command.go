@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// incidentExport is one moderation alert with the transcript lines around it.
+type incidentExport struct {
+	Record     interactionRecord
+	Categories []string
+	Lines      []transcriptLine
+}
+
+// runIncidentsCommand implements `mcchatbot incidents`: for every interaction with a
+// moderation hit (filtered like `history`), pull the -context transcript lines before
+// and after the line that triggered it, so staff can see how the situation built up
+// and how it ended.
+func runIncidentsCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("incidents", flag.ContinueOnError)
+	opts := addHistoryFlags(flags, stderr, 20)
+	transcriptPath := flags.String("transcript", strings.TrimSpace(os.Getenv("MCCHATBOT_TRANSCRIPT")), "JSONL chat transcript")
+	context := flags.Int("context", envIntOr("MCCHATBOT_INCIDENT_CONTEXT", defaultIncidentCtx), "transcript lines to include before and after each alert")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *transcriptPath == "" {
+		fmt.Fprintln(stderr, "incidents: no transcript; set MCCHATBOT_TRANSCRIPT (or -transcript) so every chat line is recorded")
+		return 2
+	}
+	opts.filter.Moderated = true
+	records, code := opts.load(stderr)
+	if code != 0 {
+		return code
+	}
+
+	var incidents []incidentExport
+	for _, r := range records {
+		inc := incidentExport{Record: r}
+		for _, event := range r.moderationEvents() {
+			if !containsFold(inc.Categories, event.Category) {
+				inc.Categories = append(inc.Categories, event.Category)
+			}
+		}
+		if r.Line > 0 {
+			lines, err := readTranscript(*transcriptPath, r.Line-int64(*context), r.Line+int64(*context))
+			if err != nil {
+				fmt.Fprintf(stderr, "incidents: %v\n", err)
+				return 1
+			}
+			inc.Lines = lines
+		}
+		incidents = append(incidents, inc)
+	}
+	err := opts.export(stdout, stderr, len(incidents), func(w io.Writer) error {
+		return writeIncidentsCSV(w, incidents)
+	}, func(w io.Writer) {
+		printIncidents(w, incidents)
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "incidents: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// printIncidents shows each alert as a block, marking the triggering line with ">>".
+func printIncidents(w io.Writer, incidents []incidentExport) {
+	if len(incidents) == 0 {
+		fmt.Fprintln(w, "No matching incidents.")
+		return
+	}
+	for i, inc := range incidents {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		r := inc.Record
+		fmt.Fprintf(w, "=== %s  %s  %s  (line %d)\n", r.Time, r.Player, strings.Join(inc.Categories, ", "), r.Line)
+		if r.Line == 0 {
+			fmt.Fprintf(w, "    (no transcript line; the alert predates MCCHATBOT_TRANSCRIPT)\n    <%s> %s\n", r.Player, r.Question)
+			continue
+		}
+		if len(inc.Lines) == 0 {
+			fmt.Fprintln(w, "    (transcript lines no longer available; they may have passed the retention window)")
+			continue
+		}
+		for _, line := range inc.Lines {
+			marker := "  "
+			if line.ID == r.Line {
+				marker = ">>"
+			}
+			fmt.Fprintf(w, "%s %d %s %s\n", marker, line.ID, line.Time, formatTranscriptLine(line))
+		}
+	}
+}
+
+// formatTranscriptLine renders chat as "<player> text" and other events as "* text".
+func formatTranscriptLine(line transcriptLine) string {
+	if line.Kind == EventChat.String() {
+		return fmt.Sprintf("<%s> %s", line.Player, line.Text)
+	}
+	return "* " + line.Text
+}
+
+// writeIncidentsCSV writes one row per transcript line, each tagged with the alert it
+// belongs to, so a reviewer can filter by incident in a spreadsheet.
+func writeIncidentsCSV(w io.Writer, incidents []incidentExport) error {
+	out := csv.NewWriter(w)
+	header := []string{"incident_time", "incident_player", "incident_categories", "incident_line", "line", "time", "kind", "player", "text", "trigger"}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for _, inc := range incidents {
+		r := inc.Record
+		prefix := []string{r.Time, r.Player, strings.Join(inc.Categories, ";"), strconv.FormatInt(r.Line, 10)}
+		for _, line := range inc.Lines {
+			row := append(append([]string{}, prefix...),
+				strconv.FormatInt(line.ID, 10), line.Time, line.Kind, line.Player, csvSafe(line.Text), strconv.FormatBool(line.ID == r.Line))
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+		if len(inc.Lines) == 0 {
+			// Keep the alert visible even without transcript lines.
+			row := append(append([]string{}, prefix...), "", r.Time, EventChat.String(), r.Player, csvSafe(r.Question), "true")
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// interactionRecord is one interaction-log entry: a JSONL line in MCCHATBOT_RESPONSE_LOG
+// and, when MCCHATBOT_HISTORY_DB is set, a row in the SQLite store.
+type interactionRecord struct {
+	Time     string           `json:"time"`
+	Event    string           `json:"event,omitempty"`
+	Player   string           `json:"player"`
+	Question string           `json:"question"`
+	Response string           `json:"response"`
+	Tools    []ToolInvocation `json:"tools,omitempty"`
+	Line     int64            `json:"line,omitempty"` // Transcript line ID of the triggering event
+}
+
+// moderationEvent is a moderation hit pulled out of a record's tool entries.
+type moderationEvent struct {
+	Source   string // moderation_match or moderation_classifier
+	Category string
+	Severity int
+}
+
+// moderationEvents finds the keyword and classifier hits recorded as tool entries.
+func (r interactionRecord) moderationEvents() []moderationEvent {
+	var events []moderationEvent
+	for _, tool := range r.Tools {
+		if tool.Name != "moderation_match" && tool.Name != "moderation_classifier" {
+			continue
+		}
+		var args struct {
+			Category string `json:"category"`
+			Severity int    `json:"severity"`
+			Flagged  *bool  `json:"flagged"`
+		}
+		if json.Unmarshal([]byte(tool.Arguments), &args) != nil || args.Category == "" {
+			continue
+		}
+		if args.Flagged != nil && !*args.Flagged {
+			continue // The classifier looked and found nothing
+		}
+		events = append(events, moderationEvent{Source: tool.Name, Category: args.Category, Severity: args.Severity})
+	}
+	return events
+}
+
+// historyFilter narrows a history query. Zero values match everything.
+type historyFilter struct {
+	Player    string
+	Since     time.Time // inclusive
+	Until     time.Time // exclusive
+	Tool      string
+	Category  string
+	Moderated bool // only interactions with a moderation hit
+	Limit     int  // most recent N; 0 returns all
+}
+
+func (f historyFilter) matches(r interactionRecord) bool {
+	if f.Player != "" && !strings.EqualFold(f.Player, r.Player) {
+		return false
+	}
+	if !f.Since.IsZero() || !f.Until.IsZero() {
+		t, err := time.Parse(time.RFC3339, r.Time)
+		if err != nil || (!f.Since.IsZero() && t.Before(f.Since)) || (!f.Until.IsZero() && !t.Before(f.Until)) {
+			return false
+		}
+	}
+	if f.Tool != "" {
+		found := false
+		for _, tool := range r.Tools {
+			found = found || strings.EqualFold(tool.Name, f.Tool)
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Category != "" || f.Moderated {
+		found := false
+		for _, event := range r.moderationEvents() {
+			found = found || f.Category == "" || strings.EqualFold(event.Category, f.Category)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// queryInteractionLog scans the JSONL log, rotated segments (plain or gzipped) first and
+// the live file last, so results come out oldest first. Unreadable lines are skipped.
+func queryInteractionLog(path string, f historyFilter) ([]interactionRecord, error) {
+	segments, err := logSegments(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []interactionRecord
+	for _, segment := range segments {
+		if err := scanLogSegment(segment, func(line []byte) bool {
+			var r interactionRecord
+			if json.Unmarshal(line, &r) == nil && f.matches(r) {
+				records = append(records, r)
+			}
+			return true
+		}); err != nil {
+			return nil, fmt.Errorf("%s: %w", segment, err)
+		}
+	}
+	if f.Limit > 0 && len(records) > f.Limit {
+		records = records[len(records)-f.Limit:]
+	}
+	return records, nil
+}
+
+// logSegments lists a rotating log's files oldest first: rotated segments, whose names
+// carry their timestamp, then the live file.
+func logSegments(path string) ([]string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	segments, err := filepath.Glob(base + "-*" + ext + "*")
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		stampI, seqI := segmentOrder(segments[i], base, ext)
+		stampJ, seqJ := segmentOrder(segments[j], base, ext)
+		if stampI != stampJ {
+			return stampI < stampJ
+		}
+		return seqI < seqJ
+	})
+	if fileExists(path) {
+		segments = append(segments, path)
+	}
+	return segments, nil
+}
+
+// segmentOrder splits a segment name into its timestamp and collision counter.
+// Sorting names as plain strings would put chat-20240601-120000-1.log before
+// chat-20240601-120000.log and -10 before -2 when several rotations share a second.
+func segmentOrder(segment, base, ext string) (string, int) {
+	name := strings.TrimSuffix(strings.TrimSuffix(segment, ".gz"), ext)
+	name = strings.TrimPrefix(name, base+"-")
+	const stampLen = len("20060102-150405")
+	if len(name) <= stampLen {
+		return name, 0
+	}
+	seq, err := strconv.Atoi(strings.TrimPrefix(name[stampLen:], "-"))
+	if err != nil {
+		return name, 0
+	}
+	return name[:stampLen], seq
+}
+
+// scanLogSegment feeds each line of a plain or gzipped segment to visit until visit
+// returns false.
+func scanLogSegment(path string, visit func(line []byte) bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		reader = zr
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024) // Tool outputs can make long lines
+	for scanner.Scan() {
+		if !visit(scanner.Bytes()) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// historyStore keeps interactions, tool invocations, and moderation events in SQLite so
+// staff can search them. The SQL only needs database/sql; the pure-Go driver is linked in
+// by building with -tags sqlite (see sqliteDriver).
+//
+// 🎓 LEARNING NOTE: A JSONL file is great for writing but slow to search - you have to
+// read every line. A database keeps indexes, like the index at the back of a book, so
+// "everything Steve said last week" is answered instantly.
+type historyStore struct {
+	db *sql.DB
+}
+
+var historySchema = []string{
+	`CREATE TABLE IF NOT EXISTS interactions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time TEXT NOT NULL,
+		event TEXT NOT NULL DEFAULT '',
+		player TEXT NOT NULL,
+		question TEXT NOT NULL,
+		response TEXT NOT NULL,
+		line INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE INDEX IF NOT EXISTS interactions_player_time ON interactions (player COLLATE NOCASE, time)`,
+	`CREATE INDEX IF NOT EXISTS interactions_time ON interactions (time)`,
+	`CREATE TABLE IF NOT EXISTS tool_invocations (
+		interaction_id INTEGER NOT NULL REFERENCES interactions (id),
+		seq INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		arguments TEXT NOT NULL DEFAULT '',
+		output TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (interaction_id, seq)
+	)`,
+	`CREATE INDEX IF NOT EXISTS tool_invocations_name ON tool_invocations (name COLLATE NOCASE)`,
+	`CREATE TABLE IF NOT EXISTS moderation_events (
+		interaction_id INTEGER NOT NULL REFERENCES interactions (id),
+		source TEXT NOT NULL,
+		category TEXT NOT NULL,
+		severity INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE INDEX IF NOT EXISTS moderation_events_category ON moderation_events (category COLLATE NOCASE)`,
+}
+
+// openHistoryStore opens (and creates) the database. An empty path disables the store.
+func openHistoryStore(path string) (*historyStore, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if sqliteDriver == "" {
+		return nil, errors.New("this binary was built without SQLite; run `make build-sqlite` (go build -tags sqlite) or unset MCCHATBOT_HISTORY_DB")
+	}
+	db, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer at a time; a single connection turns contention into a
+	// short queue instead of "database is locked" errors.
+	db.SetMaxOpenConns(1)
+	for _, stmt := range append([]string{`PRAGMA busy_timeout = 5000`}, historySchema...) {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("init %s: %w", path, err)
+		}
+	}
+	return &historyStore{db: db}, nil
+}
+
+// Close releases the database. A nil store is a no-op.
+func (s *historyStore) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Record inserts one interaction with its tool invocations and moderation events in a
+// single transaction. Times are stored as UTC RFC 3339 text, which sorts correctly.
+func (s *historyStore) Record(r interactionRecord) error {
+	if s == nil {
+		return nil
+	}
+	stamp := r.Time
+	if t, err := time.Parse(time.RFC3339, r.Time); err == nil {
+		stamp = t.UTC().Format(time.RFC3339)
+	}
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op after Commit
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO interactions (time, event, player, question, response, line) VALUES (?, ?, ?, ?, ?, ?)`,
+		stamp, r.Event, r.Player, r.Question, r.Response, r.Line)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for i, tool := range r.Tools {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO tool_invocations (interaction_id, seq, name, arguments, output, error) VALUES (?, ?, ?, ?, ?, ?)`,
+			id, i, tool.Name, tool.Arguments, tool.Output, tool.Error); err != nil {
+			return err
+		}
+	}
+	for _, event := range r.moderationEvents() {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO moderation_events (interaction_id, source, category, severity) VALUES (?, ?, ?, ?)`,
+			id, event.Source, event.Category, event.Severity); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Query returns matching interactions oldest first, with their tool invocations.
+func (s *historyStore) Query(f historyFilter) ([]interactionRecord, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if f.Player != "" {
+		where = append(where, `player = ? COLLATE NOCASE`)
+		args = append(args, f.Player)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, `time >= ?`)
+		args = append(args, f.Since.UTC().Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		where = append(where, `time < ?`)
+		args = append(args, f.Until.UTC().Format(time.RFC3339))
+	}
+	if f.Tool != "" {
+		where = append(where, `id IN (SELECT interaction_id FROM tool_invocations WHERE name = ? COLLATE NOCASE)`)
+		args = append(args, f.Tool)
+	}
+	if f.Category != "" {
+		where = append(where, `id IN (SELECT interaction_id FROM moderation_events WHERE category = ? COLLATE NOCASE)`)
+		args = append(args, f.Category)
+	} else if f.Moderated {
+		where = append(where, `id IN (SELECT interaction_id FROM moderation_events)`)
+	}
+	query := `SELECT id, time, event, player, question, response, line FROM interactions`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY time DESC, id DESC`
+	if f.Limit > 0 {
+		query += fmt.Sprintf(` LIMIT %d`, f.Limit)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		records []interactionRecord
+		ids     []int64
+	)
+	for rows.Next() {
+		var (
+			id int64
+			r  interactionRecord
+		)
+		if err := rows.Scan(&id, &r.Time, &r.Event, &r.Player, &r.Question, &r.Response, &r.Line); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		records = append(records, r)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	index := make(map[int64]int, len(ids))
+	for i := range records {
+		index[ids[i]] = i
+	}
+	if err := s.attachTools(records, ids, index); err != nil {
+		return nil, err
+	}
+	// Newest first made LIMIT keep the most recent rows; hand them back oldest first.
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// attachTools loads the tool invocations for the given interactions, a few hundred ids
+// per query to stay under SQLite's bound-parameter limit.
+func (s *historyStore) attachTools(records []interactionRecord, ids []int64, index map[int64]int) error {
+	for len(ids) > 0 {
+		chunk := ids
+		if len(chunk) > 500 {
+			chunk = chunk[:500]
+		}
+		if err := s.attachToolChunk(records, chunk, index); err != nil {
+			return err
+		}
+		ids = ids[len(chunk):]
+	}
+	return nil
+}
+
+func (s *historyStore) attachToolChunk(records []interactionRecord, ids []int64, index map[int64]int) error {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := s.db.Query(`SELECT interaction_id, name, arguments, output, error FROM tool_invocations
+		WHERE interaction_id IN (`+placeholders+`) ORDER BY interaction_id, seq`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id   int64
+			tool ToolInvocation
+		)
+		if err := rows.Scan(&id, &tool.Name, &tool.Arguments, &tool.Output, &tool.Error); err != nil {
+			return err
+		}
+		records[index[id]].Tools = append(records[index[id]].Tools, tool)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,7 @@
+//go:build !sqlite
+
+package main
+
+// sqliteDriver is empty in the default build, which has no SQLite driver linked in, so
+// openHistoryStore explains how to rebuild instead of failing deep inside database/sql.
+const sqliteDriver = ""
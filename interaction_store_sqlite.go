@@ -0,0 +1,10 @@
+//go:build sqlite
+
+package main
+
+// Built with -tags sqlite: link the pure-Go SQLite driver (no cgo) for MCCHATBOT_HISTORY_DB.
+// The driver version is pinned in go.mod; `make build-sqlite` builds with the tag.
+import _ "modernc.org/sqlite"
+
+// sqliteDriver is the database/sql driver name registered by modernc.org/sqlite.
+const sqliteDriver = "sqlite"
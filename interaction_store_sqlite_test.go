@@ -0,0 +1,35 @@
+//go:build sqlite
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryStoreRoundTrip(t *testing.T) {
+	store, err := openHistoryStore(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, r := range []interactionRecord{
+		{Time: now, Player: "Steve", Question: "hi", Response: "Hello!"},
+		{Time: now, Player: "Alex", Question: "you are trash", Response: "Let's be kind.", Tools: []ToolInvocation{
+			{Name: "moderation_match", Arguments: `{"category":"insult","severity":1}`},
+		}},
+	} {
+		if err := store.Record(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := store.Query(historyFilter{Moderated: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Player != "Alex" || len(records[0].Tools) != 1 {
+		t.Fatalf("moderated records = %+v, want Alex's one entry", records)
+	}
+}
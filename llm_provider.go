@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	providerDemeterics = "demeterics"
+	providerOpenAI     = "openai"
+	providerAnthropic  = "anthropic"
+
+	demetericsBaseURL       = "https://api.demeterics.com/groq/v1"
+	defaultOpenAIBaseURL    = "https://api.openai.com/v1"
+	defaultAnthropicBaseURL = "https://api.anthropic.com"
+	anthropicAPIVersion     = "2023-06-01"
+	anthropicDefaultTokens  = 1024
+)
+
+// ChatProvider sends one chat-completions request to an LLM backend. Requests and
+// responses always use the OpenAI-style ChatRequest/ChatResponse shapes; each provider
+// translates them to and from its native wire format, including tool calls.
+//
+// 🎓 LEARNING NOTE: Different AI companies use slightly different JSON formats for the
+// same idea. A "provider" is a translator, so the rest of Alfred never has to care!
+type ChatProvider interface {
+	Complete(ctx context.Context, req ChatRequest) (ChatResponse, error)
+}
+
+// newChatProvider builds the backend selected by MCCHATBOT_LLM_PROVIDER.
+func newChatProvider(cfg Config) (ChatProvider, error) {
+	switch cfg.LLMProvider {
+	case "", providerDemeterics:
+		return openAIProvider{baseURL: demetericsBaseURL, apiKey: cfg.APIKey, client: http.DefaultClient}, nil
+	case providerOpenAI:
+		base := cfg.LLMBaseURL
+		if base == "" {
+			base = defaultOpenAIBaseURL
+		}
+		return openAIProvider{baseURL: base, apiKey: cfg.APIKey, client: http.DefaultClient, compat: true}, nil
+	case providerAnthropic:
+		base := cfg.LLMBaseURL
+		if base == "" {
+			base = defaultAnthropicBaseURL
+		}
+		return anthropicProvider{baseURL: base, apiKey: cfg.APIKey, client: http.DefaultClient}, nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q (expected %s, %s, or %s)", cfg.LLMProvider, providerDemeterics, providerOpenAI, providerAnthropic)
+	}
+}
+
+// providerNeedsKey reports whether the provider refuses unauthenticated requests.
+// Local OpenAI-compatible servers (Ollama, llama.cpp) usually run without a key.
+func providerNeedsKey(provider string) bool {
+	return provider != providerOpenAI
+}
+
+// openAIProvider talks to any OpenAI-compatible /chat/completions endpoint, including
+// Demeterics' Groq proxy and local servers like Ollama or llama.cpp.
+type openAIProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+	// compat also sends the legacy max_tokens field, which many local servers still
+	// expect instead of max_completion_tokens.
+	compat bool
+}
+
+// openAICompatRequest adds max_tokens alongside the fields ChatRequest already carries.
+type openAICompatRequest struct {
+	ChatRequest
+	MaxTokens int `json:"max_tokens,omitempty"`
+}
+
+// Complete posts the request as-is; the OpenAI tool-call format is the bot's native one.
+func (p openAIProvider) Complete(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	var body interface{} = req
+	if p.compat {
+		body = openAICompatRequest{ChatRequest: req, MaxTokens: req.MaxCompletionTokens}
+	}
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+	var parsed ChatResponse
+	if err := postJSON(ctx, p.client, joinURL(p.baseURL, "/chat/completions"), headers, body, &parsed); err != nil {
+		return ChatResponse{}, err
+	}
+	return parsed, nil
+}
+
+// anthropicProvider translates requests to the Anthropic Messages API.
+type anthropicProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+type anthropicRequest struct {
+	Model       string               `json:"model"`
+	System      string               `json:"system,omitempty"`
+	Messages    []anthropicMessage   `json:"messages"`
+	MaxTokens   int                  `json:"max_tokens"`
+	Temperature float64              `json:"temperature,omitempty"`
+	Tools       []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock covers the text, tool_use, and tool_result content block variants.
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	InputSchema interface{} `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// Complete converts the request, calls /v1/messages, and maps the reply back so
+// chatWithTools sees ordinary tool calls.
+func (p anthropicProvider) Complete(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	body, err := toAnthropicRequest(req)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	headers := map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": anthropicAPIVersion,
+	}
+	var parsed anthropicResponse
+	if err := postJSON(ctx, p.client, joinURL(p.baseURL, "/v1/messages"), headers, body, &parsed); err != nil {
+		return ChatResponse{}, err
+	}
+	return fromAnthropicResponse(parsed), nil
+}
+
+// toAnthropicRequest hoists system prompts, turns assistant tool calls into tool_use
+// blocks and tool messages into tool_result blocks, and merges consecutive same-role
+// turns because the Messages API requires strict user/assistant alternation.
+func toAnthropicRequest(req ChatRequest) (anthropicRequest, error) {
+	out := anthropicRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxCompletionTokens,
+		Temperature: req.Temperature,
+	}
+	if out.MaxTokens <= 0 {
+		out.MaxTokens = anthropicDefaultTokens
+	}
+	var system []string
+	for _, msg := range req.Messages {
+		var (
+			role   string
+			blocks []anthropicBlock
+		)
+		switch msg.Role {
+		case "system":
+			system = append(system, msg.Content)
+			continue
+		case "tool":
+			role = "user"
+			blocks = []anthropicBlock{{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   msg.Content,
+				IsError:   strings.HasPrefix(msg.Content, "error:"),
+			}}
+		case "assistant":
+			role = "assistant"
+			if strings.TrimSpace(msg.Content) != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(strings.TrimSpace(call.Function.Arguments))
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
+				if !json.Valid(input) {
+					return anthropicRequest{}, fmt.Errorf("tool call %s has invalid JSON arguments", call.ID)
+				}
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: input})
+			}
+		default:
+			role = "user"
+			blocks = []anthropicBlock{{Type: "text", Text: msg.Content}}
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(out.Messages); n > 0 && out.Messages[n-1].Role == role {
+			out.Messages[n-1].Content = append(out.Messages[n-1].Content, blocks...)
+			continue
+		}
+		out.Messages = append(out.Messages, anthropicMessage{Role: role, Content: blocks})
+	}
+	if len(out.Messages) == 0 {
+		return anthropicRequest{}, errors.New("anthropic request has no messages")
+	}
+	out.System = strings.Join(system, "\n\n")
+	for _, tool := range req.Tools {
+		schema := tool.Function.Parameters
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object"}
+		}
+		out.Tools = append(out.Tools, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	if len(out.Tools) > 0 {
+		out.ToolChoice = toAnthropicToolChoice(req.ToolChoice)
+	}
+	return out, nil
+}
+
+// toAnthropicToolChoice maps "auto", "none", "required", and the OpenAI named-function
+// object onto Anthropic's tool_choice variants.
+func toAnthropicToolChoice(choice interface{}) *anthropicToolChoice {
+	switch v := choice.(type) {
+	case string:
+		switch v {
+		case "none":
+			return &anthropicToolChoice{Type: "none"}
+		case "required":
+			return &anthropicToolChoice{Type: "any"}
+		default:
+			return &anthropicToolChoice{Type: "auto"}
+		}
+	case map[string]interface{}:
+		if fn, ok := v["function"].(map[string]interface{}); ok {
+			if name, ok := fn["name"].(string); ok && name != "" {
+				return &anthropicToolChoice{Type: "tool", Name: name}
+			}
+		}
+	}
+	return &anthropicToolChoice{Type: "auto"}
+}
+
+// fromAnthropicResponse folds text blocks into Content and tool_use blocks into ToolCalls,
+// and maps stop_reason onto the OpenAI finish_reason values.
+func fromAnthropicResponse(resp anthropicResponse) ChatResponse {
+	msg := Message{Role: "assistant"}
+	var text []string
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			text = append(text, block.Text)
+		case "tool_use":
+			args := string(block.Input)
+			if args == "" {
+				args = "{}"
+			}
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: ToolCallFunction{Name: block.Name, Arguments: args},
+			})
+		}
+	}
+	msg.Content = strings.Join(text, "")
+	return ChatResponse{
+		Choices: []Choice{{Message: msg, FinishReason: fromAnthropicStopReason(resp.StopReason)}},
+		Usage:   Usage{TotalTokens: resp.Usage.InputTokens + resp.Usage.OutputTokens},
+	}
+}
+
+// fromAnthropicStopReason translates why the model stopped. Unknown reasons pass through
+// unchanged rather than being guessed at.
+func fromAnthropicStopReason(reason string) string {
+	switch reason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "tool_use":
+		return "tool_calls"
+	case "max_tokens":
+		return "length"
+	}
+	return reason
+}
+
+// postJSON marshals body, POSTs it with the given headers, and decodes a 2xx reply into
+// out. Non-2xx responses become classified *llmError values for the retry loop.
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(resp.Body)
+		return newHTTPError(resp, string(data))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// joinURL appends an API path to a base URL without doubling slashes.
+func joinURL(base, path string) string {
+	return strings.TrimRight(base, "/") + path
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeAnthropic serves one canned /v1/messages reply and keeps the request it received.
+func fakeAnthropic(t *testing.T, reply string) (*httptest.Server, *[]byte) {
+	t.Helper()
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("path = %s, want /v1/messages", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") != anthropicAPIVersion {
+			t.Errorf("headers = %v", r.Header)
+		}
+		body, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(server.Close)
+	return server, &body
+}
+
+func TestAnthropicProviderRoundTrip(t *testing.T) {
+	server, sent := fakeAnthropic(t, `{
+		"content": [
+			{"type": "text", "text": "Off you go, "},
+			{"type": "text", "text": "Steve!"},
+			{"type": "tool_use", "id": "toolu_3", "name": "mini_firework", "input": {"player":"Steve"}}
+		],
+		"stop_reason": "tool_use",
+		"usage": {"input_tokens": 40, "output_tokens": 12}
+	}`)
+	provider := anthropicProvider{baseURL: server.URL + "/", apiKey: "test-key", client: server.Client()}
+	req := ChatRequest{
+		Model: "claude-test",
+		Messages: []Message{
+			{Role: "system", Content: "You are Alfred."},
+			{Role: "user", Content: "take me home and do fireworks"},
+			{Role: "assistant", Content: "On it.", ToolCalls: []ToolCall{
+				{ID: "toolu_1", Type: "function", Function: ToolCallFunction{Name: teleportToolName, Arguments: `{"destination":"home"}`}},
+				{ID: "toolu_2", Type: "function", Function: ToolCallFunction{Name: fireworkToolName, Arguments: ""}},
+			}},
+			{Role: "tool", ToolCallID: "toolu_1", Content: "Teleported Steve home."},
+			{Role: "tool", ToolCallID: "toolu_2", Content: "error: Steve is offline"},
+			{Role: "system", Content: "Keep it short."},
+		},
+		Tools:      []ToolDefinition{fireworkToolDefinition()},
+		ToolChoice: "none",
+	}
+
+	resp, err := provider.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got anthropicRequest
+	if err := json.Unmarshal(*sent, &got); err != nil {
+		t.Fatalf("request body %s: %v", *sent, err)
+	}
+	if got.System != "You are Alfred.\n\nKeep it short." {
+		t.Errorf("system = %q, want both system messages hoisted", got.System)
+	}
+	if got.MaxTokens != anthropicDefaultTokens {
+		t.Errorf("max_tokens = %d, want the default %d", got.MaxTokens, anthropicDefaultTokens)
+	}
+	if got.ToolChoice == nil || got.ToolChoice.Type != "none" {
+		t.Errorf("tool_choice = %+v, want none", got.ToolChoice)
+	}
+	if len(got.Tools) != 1 || got.Tools[0].Name != fireworkToolName || got.Tools[0].InputSchema == nil {
+		t.Errorf("tools = %+v", got.Tools)
+	}
+	if len(got.Messages) != 3 {
+		t.Fatalf("messages = %+v, want user, assistant, user", got.Messages)
+	}
+	for i, role := range []string{"user", "assistant", "user"} {
+		if got.Messages[i].Role != role {
+			t.Errorf("message %d role = %q, want %q", i, got.Messages[i].Role, role)
+		}
+	}
+
+	assistant := got.Messages[1].Content
+	if len(assistant) != 3 || assistant[0].Type != "text" || assistant[0].Text != "On it." {
+		t.Fatalf("assistant blocks = %+v, want text then two tool_use", assistant)
+	}
+	if b := assistant[1]; b.Type != "tool_use" || b.ID != "toolu_1" || b.Name != teleportToolName || string(b.Input) != `{"destination":"home"}` {
+		t.Errorf("first tool_use = %+v", b)
+	}
+	if b := assistant[2]; b.Type != "tool_use" || b.ID != "toolu_2" || string(b.Input) != "{}" {
+		t.Errorf("second tool_use = %+v, want empty arguments sent as {}", b)
+	}
+
+	results := got.Messages[2].Content
+	if len(results) != 2 {
+		t.Fatalf("tool results = %+v, want both merged into one user turn", results)
+	}
+	if b := results[0]; b.Type != "tool_result" || b.ToolUseID != "toolu_1" || b.IsError {
+		t.Errorf("first tool_result = %+v", b)
+	}
+	if b := results[1]; b.Type != "tool_result" || b.ToolUseID != "toolu_2" || !b.IsError {
+		t.Errorf("second tool_result = %+v, want is_error for an error: result", b)
+	}
+	if !strings.Contains(string(*sent), `"tool_use_id":"toolu_1"`) {
+		t.Errorf("request body %s lacks the tool_use_id field", *sent)
+	}
+
+	if len(resp.Choices) != 1 {
+		t.Fatalf("choices = %+v", resp.Choices)
+	}
+	choice := resp.Choices[0]
+	if choice.Message.Role != "assistant" || choice.Message.Content != "Off you go, Steve!" {
+		t.Errorf("message = %+v", choice.Message)
+	}
+	if calls := choice.Message.ToolCalls; len(calls) != 1 || calls[0].ID != "toolu_3" || calls[0].Type != "function" ||
+		calls[0].Function.Name != fireworkToolName || calls[0].Function.Arguments != `{"player":"Steve"}` {
+		t.Errorf("tool calls = %+v", calls)
+	}
+	if choice.FinishReason != "tool_calls" {
+		t.Errorf("finish reason = %q, want tool_calls", choice.FinishReason)
+	}
+	if resp.Usage.TotalTokens != 52 {
+		t.Errorf("total tokens = %d, want 52", resp.Usage.TotalTokens)
+	}
+}
+
+func TestAnthropicProviderReportsHTTPErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"type":"error","error":{"type":"rate_limit_error"}}`)
+	}))
+	defer server.Close()
+	provider := anthropicProvider{baseURL: server.URL, apiKey: "test-key", client: server.Client()}
+	_, err := provider.Complete(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "hi"}}})
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("err = %v, want the 429 status", err)
+	}
+}
+
+func TestToAnthropicRequestRejectsBadInput(t *testing.T) {
+	if _, err := toAnthropicRequest(ChatRequest{Messages: []Message{{Role: "system", Content: "only a prompt"}}}); err == nil {
+		t.Error("a request with only system messages was accepted")
+	}
+	bad := ChatRequest{Messages: []Message{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "toolu_1", Function: ToolCallFunction{Name: "x", Arguments: `{"player":`}}}},
+	}}
+	if _, err := toAnthropicRequest(bad); err == nil || !strings.Contains(err.Error(), "toolu_1") {
+		t.Errorf("err = %v, want the broken tool call named", err)
+	}
+}
+
+func TestToAnthropicToolChoice(t *testing.T) {
+	cases := []struct {
+		choice interface{}
+		want   anthropicToolChoice
+	}{
+		{nil, anthropicToolChoice{Type: "auto"}},
+		{"auto", anthropicToolChoice{Type: "auto"}},
+		{"none", anthropicToolChoice{Type: "none"}},
+		{"required", anthropicToolChoice{Type: "any"}},
+		{map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "set_time"}}, anthropicToolChoice{Type: "tool", Name: "set_time"}},
+	}
+	for _, tc := range cases {
+		if got := toAnthropicToolChoice(tc.choice); *got != tc.want {
+			t.Errorf("tool choice %v = %+v, want %+v", tc.choice, *got, tc.want)
+		}
+	}
+}
+
+func TestFromAnthropicStopReason(t *testing.T) {
+	cases := map[string]string{
+		"end_turn":      "stop",
+		"stop_sequence": "stop",
+		"tool_use":      "tool_calls",
+		"max_tokens":    "length",
+		"refusal":       "refusal",
+		"":              "",
+	}
+	for reason, want := range cases {
+		resp := fromAnthropicResponse(anthropicResponse{StopReason: reason})
+		if got := resp.Choices[0].FinishReason; got != want {
+			t.Errorf("stop_reason %q = %q, want %q", reason, got, want)
+		}
+	}
+}
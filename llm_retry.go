@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// llmErrorKind buckets provider failures so the retry loop knows what to do next.
+type llmErrorKind int
+
+const (
+	llmErrUnknown llmErrorKind = iota
+	llmErrRateLimit
+	llmErrServer
+	llmErrTimeout
+	llmErrNetwork
+	llmErrBadRequest
+	llmErrAuth
+)
+
+// String returns the label used in log lines.
+func (k llmErrorKind) String() string {
+	switch k {
+	case llmErrRateLimit:
+		return "rate_limit"
+	case llmErrServer:
+		return "server_error"
+	case llmErrTimeout:
+		return "timeout"
+	case llmErrNetwork:
+		return "network"
+	case llmErrBadRequest:
+		return "bad_request"
+	case llmErrAuth:
+		return "auth"
+	default:
+		return "unknown"
+	}
+}
+
+// llmError is returned for non-2xx provider responses. It keeps the status and any
+// Retry-After hint so the caller can back off exactly as long as the provider asked.
+type llmError struct {
+	Kind       llmErrorKind
+	Status     string
+	RetryAfter time.Duration
+	Body       string
+}
+
+func (e *llmError) Error() string {
+	return fmt.Sprintf("api error (%s): %s - %s", e.Kind, e.Status, e.Body)
+}
+
+// newHTTPError classifies an HTTP failure by status code and parses Retry-After.
+func newHTTPError(resp *http.Response, body string) *llmError {
+	kind := llmErrUnknown
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		kind = llmErrRateLimit
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusGatewayTimeout:
+		kind = llmErrTimeout
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		kind = llmErrAuth
+	case resp.StatusCode >= 500:
+		kind = llmErrServer
+	case resp.StatusCode >= 400:
+		kind = llmErrBadRequest
+	}
+	return &llmError{
+		Kind:       kind,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		Body:       strings.TrimSpace(body),
+	}
+}
+
+// parseRetryAfter accepts both forms the header allows: delta seconds or an HTTP date.
+func parseRetryAfter(raw string, now time.Time) time.Duration {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(raw); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if when, err := http.ParseTime(raw); err == nil && when.After(now) {
+		return when.Sub(now)
+	}
+	return 0
+}
+
+// classifyLLMError maps any error from a provider call onto an llmErrorKind.
+func classifyLLMError(err error) llmErrorKind {
+	var apiErr *llmError
+	if errors.As(err, &apiErr) {
+		return apiErr.Kind
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return llmErrTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return llmErrTimeout
+		}
+		return llmErrNetwork
+	}
+	return llmErrUnknown
+}
+
+// retryableLLMError reports whether trying the same model again could succeed.
+func retryableLLMError(kind llmErrorKind) bool {
+	switch kind {
+	case llmErrRateLimit, llmErrServer, llmErrTimeout, llmErrNetwork:
+		return true
+	}
+	return false
+}
+
+// completeWithRetry walks the primary model and then each fallback, retrying transient
+// failures with jittered exponential backoff. Bad requests skip straight to the next
+// model (it may not support a feature the first one rejected); auth failures stop at
+// once because no amount of retrying fixes a wrong key.
+//
+// 🎓 LEARNING NOTE: "Exponential backoff" means waiting 0.5s, then 1s, then 2s...
+// The random "jitter" stops many clients from retrying at the exact same moment.
+func completeWithRetry(ctx context.Context, cfg Config, reqBody ChatRequest) (ChatResponse, error) {
+	models := append([]string{reqBody.Model}, cfg.FallbackModels...)
+	retries := max(cfg.LLMRetries, 0) // Every model gets at least one attempt
+	var lastErr error
+	for _, model := range models {
+		req := reqBody
+		req.Model = model
+		for attempt := 0; attempt <= retries; attempt++ {
+			resp, err := completeOnce(ctx, cfg, req)
+			if err == nil {
+				return resp, nil
+			}
+			if ctx.Err() != nil {
+				return ChatResponse{}, ctx.Err()
+			}
+			lastErr = err
+			kind := classifyLLMError(err)
+			log.Printf("LLM %s attempt %d/%d failed (%s): %v", model, attempt+1, retries+1, kind, err)
+			if kind == llmErrAuth {
+				return ChatResponse{}, err
+			}
+			if !retryableLLMError(kind) || attempt == retries {
+				break
+			}
+			if err := sleepContext(ctx, retryDelay(cfg, attempt, err)); err != nil {
+				return ChatResponse{}, err
+			}
+		}
+	}
+	return ChatResponse{}, lastErr
+}
+
+// completeOnce bounds a single provider call by the per-request timeout.
+func completeOnce(ctx context.Context, cfg Config, req ChatRequest) (ChatResponse, error) {
+	if cfg.LLMRequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.LLMRequestTimeout)
+		defer cancel()
+	}
+	return cfg.LLM.Complete(ctx, req)
+}
+
+// retryDelay doubles the base backoff per attempt, caps it, and applies equal jitter (a
+// random delay between half the backoff and all of it, so retries never fire back to
+// back). A provider-supplied Retry-After wins when longer, up to the same cap (the
+// request timeout when no backoff cap is set), so "Retry-After: 3600" cannot park a
+// worker lane for an hour.
+func retryDelay(cfg Config, attempt int, err error) time.Duration {
+	backoff := cfg.LLMBackoff << attempt
+	if backoff <= 0 || (cfg.LLMBackoffMax > 0 && backoff > cfg.LLMBackoffMax) {
+		backoff = cfg.LLMBackoffMax
+	}
+	var delay time.Duration
+	if backoff > 0 {
+		delay = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	}
+	var apiErr *llmError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > delay {
+		delay = apiErr.RetryAfter
+	}
+	limit := cfg.LLMBackoffMax
+	if limit <= 0 {
+		limit = cfg.LLMRequestTimeout
+	}
+	if limit > 0 && delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// sleepContext waits for d or until ctx is cancelled, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
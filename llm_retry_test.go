@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingProvider fails every call with err and counts the attempts.
+type failingProvider struct {
+	err   error
+	calls int
+}
+
+func (p *failingProvider) Complete(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	p.calls++
+	return ChatResponse{}, p.err
+}
+
+func TestCompleteWithRetryNegativeRetries(t *testing.T) {
+	provider := &failingProvider{err: &llmError{Kind: llmErrServer, Status: "503 Service Unavailable"}}
+	cfg := Config{LLM: provider, LLMRetries: -3}
+
+	_, err := completeWithRetry(context.Background(), cfg, ChatRequest{Model: "small"})
+	if err == nil {
+		t.Fatal("expected the provider error, got nil")
+	}
+	if provider.calls != 1 {
+		t.Fatalf("calls = %d, want one attempt", provider.calls)
+	}
+}
+
+func TestRetryDelayJitterAndCaps(t *testing.T) {
+	hour := &llmError{Kind: llmErrRateLimit, RetryAfter: time.Hour}
+	cases := []struct {
+		name     string
+		cfg      Config
+		attempt  int
+		err      error
+		min, max time.Duration
+	}{
+		{"backoff cap", Config{LLMBackoff: 500 * time.Millisecond, LLMBackoffMax: 8 * time.Second}, 1, hour, 8 * time.Second, 8 * time.Second},
+		{"request timeout without cap", Config{LLMRequestTimeout: 20 * time.Second}, 1, hour, 20 * time.Second, 20 * time.Second},
+		{"short retry-after honored", Config{LLMBackoff: 100 * time.Millisecond, LLMBackoffMax: 8 * time.Second}, 1, &llmError{Kind: llmErrRateLimit, RetryAfter: 3 * time.Second}, 3 * time.Second, 3 * time.Second},
+		// Equal jitter: attempt 1 doubles the 1s base to 2s, then waits between 1s and 2s.
+		{"equal jitter", Config{LLMBackoff: time.Second, LLMBackoffMax: 8 * time.Second}, 1, errors.New("boom"), time.Second, 2 * time.Second},
+		// Attempt 3 would be 8s; the 3s cap applies before the jitter.
+		{"jitter under the cap", Config{LLMBackoff: time.Second, LLMBackoffMax: 3 * time.Second}, 3, errors.New("boom"), 1500 * time.Millisecond, 3 * time.Second},
+	}
+	for _, tc := range cases {
+		for i := 0; i < 200; i++ {
+			if got := retryDelay(tc.cfg, tc.attempt, tc.err); got < tc.min || got > tc.max {
+				t.Errorf("%s: delay = %v, want [%v, %v]", tc.name, got, tc.min, tc.max)
+				break
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":                              0,
+		"3600":                          time.Hour,
+		"-5":                            0,
+		"soon":                          0,
+		"Fri, 16 Oct 2026 12:00:30 GMT": 30 * time.Second,
+		"Fri, 16 Oct 2026 11:59:00 GMT": 0,
+	}
+	for raw, want := range cases {
+		if got := parseRetryAfter(raw, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", raw, got, want)
+		}
+	}
+}
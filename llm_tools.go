@@ -1,17 +1,12 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"math"
-	"net/http"
-	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 	"time"
@@ -74,7 +69,8 @@ type weatherArguments struct {
 	State string `json:"state"`
 }
 
-// Message and related structs match the Demeterics chat-completions API payload/response.
+// Message and related structs match the OpenAI-style chat-completions payload/response
+// that Demeterics speaks natively; other providers translate to and from these shapes.
 type Message struct {
 	Role       string     `json:"role"`
 	Content    string     `json:"content,omitempty"`
@@ -101,6 +97,7 @@ type ChatRequest struct {
 	MaxCompletionTokens int              `json:"max_completion_tokens,omitempty"`
 	Tools               []ToolDefinition `json:"tools,omitempty"`
 	ToolChoice          interface{}      `json:"tool_choice,omitempty"`
+	ResponseFormat      interface{}      `json:"response_format,omitempty"`
 }
 
 type ChatResponse struct {
@@ -109,7 +106,8 @@ type ChatResponse struct {
 }
 
 type Choice struct {
-	Message Message `json:"message"`
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason,omitempty"`
 }
 
 type Usage struct {
@@ -117,88 +115,140 @@ type Usage struct {
 }
 
 // callLLM prepares the conversation, tool list, and routing state before handing control
-// to chatWithTools, returning the final model response plus any tool logs. It is the
-// single entry point the rest of the bot uses to talk to Demeterics.
+// to chatWithTools, runs the answer through the output filter, and returns the reply to
+// post plus any tool logs. It is the single entry point the rest of the bot uses to talk
+// to the LLM provider, and it records each finished exchange in conversation memory so
+// follow-up questions have context.
 //
 // 🎓 LEARNING NOTE: This is how we talk to the AI! We send:
 // 1. System prompt (Alfred's personality & instructions)
-// 2. User message (what the player said)
-// 3. Available tools (functions Alfred can call, like /tp or /time)
+// 2. Recent conversation history (so Alfred remembers what you just asked)
+// 3. User message (what the player said)
+// 4. Available tools (functions Alfred can call, like /tp or /time)
 func callLLM(ctx context.Context, cfg Config, evt ChatEvent, userMessage string) (string, []ToolInvocation, error) {
-	tools, executors := availableTooling(cfg)
-	messages := []Message{
-		{Role: "system", Content: cfg.SystemPrompt}, // "You are Alfred, the camp counselor..."
-		{Role: "user", Content: fmt.Sprintf("Player %s says: %s", evt.Player, userMessage)},
+	tools := cfg.Tools.Available(cfg, evt.Player)
+	userContent := fmt.Sprintf("Player %s says: %s", evt.Player, userMessage)
+	if evt.Kind != EventChat {
+		// Server events have no spoken words, so frame the prompt as a narrated event.
+		userContent = fmt.Sprintf("Server event (%s): %s", evt.Kind, userMessage)
+	}
+	now := time.Now()
+	systemPrompt := cfg.SystemPrompt // "You are Alfred, the camp counselor..."
+	if roster := cfg.Roster.Context(); roster != "" {
+		systemPrompt += "\n\n" + roster
+	}
+	messages := []Message{{Role: "system", Content: systemPrompt}}
+	messages = append(messages, cfg.Memory.Recall(evt.Player, now)...)
+	messages = append(messages, Message{Role: "user", Content: userContent})
+	prefix := len(messages) - 1
+
+	resp, toolLogs, transcript, err := chatWithTools(ctx, cfg, evt, messages, tools)
+	if err != nil {
+		return "", toolLogs, err
 	}
-	return chatWithTools(ctx, cfg, evt, messages, tools, executors)
+	// 🎓 LEARNING NOTE: Safety check on the way OUT too - a reply that breaks the rules is
+	// regenerated once, and if it is still not OK a safe canned line goes out instead
+	resp, filterLogs := finalizeReply(ctx, cfg, userMessage, resp)
+	toolLogs = append(toolLogs, filterLogs...)
+	// Remember the user turn, any tool calls/results, and the reply that will actually be
+	// posted as one exchange; a rejected draft must never be fed back to the model.
+	exchange := append([]Message{}, transcript[prefix:]...)
+	exchange = append(exchange, Message{Role: "assistant", Content: resp})
+	cfg.Memory.Record(evt.Player, exchange, now)
+	return resp, toolLogs, nil
 }
 
 // chatWithTools manages the iterative tool-call loop, executing helper functions when
 // requested and re-feeding their output to the LLM until a final answer is produced.
-// The helper keeps transcripts tidy so the main routine only sees the result.
+// The helper keeps transcripts tidy so the main routine only sees the result; the full
+// message list (including tool traffic) is returned for conversation memory.
 //
 // 🎓 LEARNING NOTE: This is the "tool calling loop"! Here's how it works:
 // 1. Send conversation + tool definitions to AI
 // 2. AI responds with either: text answer OR tool call request
 // 3. If tool call: execute it (e.g., run `/tp player1 player2`), add result to conversation
-// 4. Loop back to step 1 (up to 3 times) until AI gives final text answer
+// 4. Loop back to step 1 (up to MCCHATBOT_TOOL_HOPS times) until AI gives final text answer
+// 5. Out of hops? One last turn with tools switched off makes the AI sum up what happened
 //
 // Example: Player: "Alfred tp me to Steve"
 //
 //	Loop 1: AI calls teleport_player(target="Steve") → we run command → success message
 //	Loop 2: AI sees success, responds: "Done! You're now with Steve 🎯"
-func chatWithTools(ctx context.Context, cfg Config, evt ChatEvent, messages []Message, tools []ToolDefinition, executors map[string]ToolExecutor) (string, []ToolInvocation, error) {
+func chatWithTools(ctx context.Context, cfg Config, evt ChatEvent, messages []Message, tools []Tool) (string, []ToolInvocation, []Message, error) {
 	totalTokens := 0
 	var toolLogs []ToolInvocation
-	for hop := 0; hop < 3; hop++ { // Max 3 hops prevents infinite loops
+	definitions := cfg.Tools.Definitions(cfg, tools)
+	seen := make(map[string]bool) // Tool calls already run, keyed by name + arguments
+	hops := cfg.ToolHops
+	if hops < 1 {
+		hops = 1
+	}
+	for hop := 0; hop < hops; hop++ { // The hop budget prevents infinite loops
 		var toolChoice interface{}
-		if len(tools) > 0 {
+		if len(definitions) > 0 {
 			toolChoice = "auto"
 		}
-		// Send full conversation plus tool schema upstream to Demeterics.
+		// Send full conversation plus tool schema upstream to the provider.
 		reqBody := ChatRequest{
 			Model:               cfg.Model,
 			Messages:            messages,
-			Temperature:         0.7,
-			MaxCompletionTokens: 200,
-			Tools:               tools,
+			Temperature:         cfg.Temperature,
+			MaxCompletionTokens: cfg.MaxTokens,
+			Tools:               definitions,
 			ToolChoice:          toolChoice,
 		}
 		resp, err := doChatCompletion(ctx, cfg, reqBody)
 		if err != nil {
-			return "", toolLogs, err
+			return "", toolLogs, messages, err
 		}
 		totalTokens += resp.Usage.TotalTokens
 		if len(resp.Choices) == 0 {
-			return "", toolLogs, errors.New("no choices returned")
+			return "", toolLogs, messages, errors.New("no choices returned")
 		}
 		msg := resp.Choices[0].Message
 
 		// 🎓 LEARNING NOTE: Check if AI wants to call a tool (function)
-		if len(msg.ToolCalls) > 0 && len(executors) > 0 {
-			messages = append(messages, msg) // Add AI's tool request to conversation
-			handled := false
-			for _, call := range msg.ToolCalls {
-				exec, ok := executors[call.Function.Name]
-				if !ok {
-					continue // Tool not found, skip
-				}
-				handled = true
-				invocation := ToolInvocation{
-					Name:      call.Function.Name,
-					Arguments: strings.TrimSpace(call.Function.Arguments),
+		if len(msg.ToolCalls) > 0 && len(tools) > 0 {
+			// 🎓 LEARNING NOTE: Sometimes an AI gets stuck asking for the exact same thing
+			// again and again. Running it twice won't help, so we stop and let it explain.
+			// The same goes for a call repeated within one turn: it runs once, and slot
+			// maps each call to its result in fresh (-1 for a repeat).
+			var fresh []ToolCall
+			slot := make([]int, len(msg.ToolCalls))
+			for i, call := range msg.ToolCalls {
+				signature := toolCallSignature(call)
+				if seen[signature] {
+					slot[i] = -1
+					continue
 				}
+				seen[signature] = true
+				slot[i] = len(fresh)
+				fresh = append(fresh, call)
+			}
+			if len(fresh) == 0 {
+				log.Printf("[TOOL] %s: model repeated its tool calls; asking for a summary", evt.Player)
+				break
+			}
+			messages = append(messages, msg) // Add AI's tool request to conversation
 
-				// 🎓 LEARNING NOTE: Execute the tool! This runs the actual Minecraft command
-				// For example: exec() might run "tp Steve Alice" via screen
-				output, err := exec(ctx, cfg, evt, call)
-				if err != nil {
-					output = fmt.Sprintf("error: %v", err)
-					invocation.Error = err.Error()
+			// 🎓 LEARNING NOTE: Execute the tools! The registry checks the arguments, then
+			// runs the actual Minecraft commands, e.g. "tp Steve Alice" via screen.
+			// Independent calls run side by side; results still come back in order.
+			// Unknown tools come back as an error the AI can read, never a silent skip
+			invocations := cfg.Tools.RunAll(ctx, cfg, evt, tools, fresh)
+			for i, call := range msg.ToolCalls {
+				var output string
+				if slot[i] < 0 {
+					// Every call ID needs an answer, so a repeat gets a note instead of a rerun.
+					output = "error: this exact call already ran earlier; see its result above"
 				} else {
-					invocation.Output = output
+					invocation := invocations[slot[i]]
+					output = invocation.Output
+					if invocation.Error != "" {
+						output = "error: " + invocation.Error
+					}
+					toolLogs = append(toolLogs, invocation)
 				}
-				toolLogs = append(toolLogs, invocation)
 
 				// 🎓 LEARNING NOTE: Add tool result back to conversation so AI knows what happened
 				// This is like saying: "I ran the command, here's what happened"
@@ -209,107 +259,197 @@ func chatWithTools(ctx context.Context, cfg Config, evt ChatEvent, messages []Me
 					Content:    output, // "Teleported Steve to Alice" or "error: player not found"
 				})
 			}
-			if handled {
-				continue // Loop again - AI will see tool results and craft final response
-			}
-			return "", toolLogs, fmt.Errorf("no executor available for requested tool")
+			continue // Loop again - AI will see tool results and craft final response
 		}
 		content := strings.TrimSpace(msg.Content)
 		if content == "" {
 			content = "I'm here if anyone needs help!"
 		}
 		log.Printf("Tokens used: %d", totalTokens)
-		return content, toolLogs, nil
+		return content, toolLogs, messages, nil
 	}
-	return "", toolLogs, errors.New("tool routing exceeded attempts")
-}
 
-// doChatCompletion performs the HTTPS request to Demeterics and decodes the response body.
-// It isolates HTTP handling so retries or error logging can be updated in one place.
-func doChatCompletion(ctx context.Context, cfg Config, reqBody ChatRequest) (ChatResponse, error) {
-	payload, err := json.Marshal(reqBody)
+	// 🎓 LEARNING NOTE: Out of hops (or stuck in a loop), but tools may already have run!
+	// One last turn with tool_choice "none" forces the AI to describe what happened
+	// instead of asking for yet another tool, so the camper still gets an answer.
+	content, tokens, err := summarizeToolTurn(ctx, cfg, messages, definitions)
+	totalTokens += tokens
 	if err != nil {
-		return ChatResponse{}, err
-	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.demeterics.com/groq/v1/chat/completions", bytes.NewReader(payload))
+		if len(toolLogs) == 0 {
+			return "", toolLogs, messages, fmt.Errorf("tool routing exceeded attempts: %w", err)
+		}
+		log.Printf("tool summary error for %s: %v", evt.Player, err)
+		content = toolRecap(toolLogs)
+	}
+	log.Printf("Tokens used: %d", totalTokens)
+	return content, toolLogs, messages, nil
+}
+
+// summarizeToolTurn asks the model for a plain answer about the tool results so far.
+// The nudge is sent but not added to messages, so conversation memory stays clean.
+func summarizeToolTurn(ctx context.Context, cfg Config, messages []Message, definitions []ToolDefinition) (string, int, error) {
+	final := append(append([]Message{}, messages...), Message{
+		Role:    "system",
+		Content: "No more tools this turn. In one or two friendly sentences, tell the camper what the tool results above show happened, including anything that failed.",
+	})
+	reqBody := ChatRequest{
+		Model:               cfg.Model,
+		Messages:            final,
+		Temperature:         cfg.Temperature,
+		MaxCompletionTokens: cfg.MaxTokens,
+	}
+	if len(definitions) > 0 {
+		// Tools stay declared because the history contains tool calls; "none" forbids new ones.
+		reqBody.Tools = definitions
+		reqBody.ToolChoice = "none"
+	}
+	resp, err := doChatCompletion(ctx, cfg, reqBody)
 	if err != nil {
-		return ChatResponse{}, err
+		return "", 0, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	if len(resp.Choices) == 0 {
+		return "", resp.Usage.TotalTokens, errors.New("no choices returned")
+	}
+	content := strings.TrimSpace(resp.Choices[0].Message.Content)
+	if content == "" {
+		return "", resp.Usage.TotalTokens, errors.New("empty summary")
+	}
+	return content, resp.Usage.TotalTokens, nil
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return ChatResponse{}, err
+// toolRecap is the last-resort reply when even the summary turn fails: a short list of
+// what worked and what didn't, built straight from the tool logs.
+func toolRecap(toolLogs []ToolInvocation) string {
+	var done, failed []string
+	for _, invocation := range toolLogs {
+		if invocation.Error != "" {
+			failed = append(failed, invocation.Name)
+		} else {
+			done = append(done, invocation.Name)
+		}
+	}
+	var parts []string
+	if len(done) > 0 {
+		parts = append(parts, "Done: "+strings.Join(done, ", ")+".")
 	}
-	defer resp.Body.Close()
+	if len(failed) > 0 {
+		parts = append(parts, "Couldn't finish: "+strings.Join(failed, ", ")+".")
+	}
+	return strings.Join(parts, " ")
+}
 
-	if resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return ChatResponse{}, fmt.Errorf("api error: %s - %s", resp.Status, string(body))
+// toolCallSignature identifies a call by name and arguments. Arguments are re-encoded
+// so key order and spacing don't hide a repeat.
+func toolCallSignature(call ToolCall) string {
+	args := strings.TrimSpace(call.Function.Arguments)
+	var decoded interface{}
+	if json.Unmarshal([]byte(args), &decoded) == nil {
+		if canonical, err := json.Marshal(decoded); err == nil {
+			args = string(canonical)
+		}
 	}
+	return call.Function.Name + " " + args
+}
 
-	var parsed ChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return ChatResponse{}, err
+// doChatCompletion hands the request to the configured LLM provider and returns the
+// decoded response. Transient failures are retried with backoff and then routed to the
+// fallback models, so callers only see an error once every option is exhausted.
+func doChatCompletion(ctx context.Context, cfg Config, reqBody ChatRequest) (ChatResponse, error) {
+	if cfg.LLM == nil {
+		return ChatResponse{}, errors.New("no LLM provider configured")
 	}
-	return parsed, nil
+	return completeWithRetry(ctx, cfg, reqBody)
 }
 
 // logInteraction appends a JSONL record for every answered chat so moderators can audit.
 // The file doubles as a lightweight transcript when parents or staff raise concerns.
-func logInteraction(path string, evt ChatEvent, response string, tools []ToolInvocation) error {
-	if path == "" {
+// Lines go through the log's writer goroutine, which also handles rotation; the same
+// record goes to the SQLite history store when one is configured.
+func logInteraction(cfg Config, evt ChatEvent, response string, tools []ToolInvocation) error {
+	if cfg.Interactions == nil && cfg.History == nil {
 		return nil
 	}
 	t := evt.Time
 	if t.IsZero() {
 		t = time.Now()
 	}
-	entry := struct {
-		Time     string           `json:"time"`
-		Player   string           `json:"player"`
-		Question string           `json:"question"`
-		Response string           `json:"response"`
-		Tools    []ToolInvocation `json:"tools,omitempty"`
-	}{
+	var event string
+	if evt.Kind != EventChat {
+		event = evt.Kind.String()
+	}
+	entry := interactionRecord{
 		Time:     t.Format(time.RFC3339),
+		Event:    event,
 		Player:   evt.Player,
 		Question: evt.Text,
 		Response: response,
 		Tools:    tools,
+		Line:     evt.Line,
+	}
+	if err := cfg.History.Record(entry); err != nil {
+		log.Printf("history store error: %v", err)
+	}
+	if cfg.Interactions == nil {
+		return nil
 	}
 	data, err := json.Marshal(entry)
 	if err != nil {
 		return err
 	}
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	return cfg.Interactions.Write(data)
+}
+
+// sendToMinecraft sanitizes the final response and broadcasts it through the console transport.
+// It protects against accidental multi-line posts that could break the console layout, and
+// runs every generated line through the output filter as a last line of defence.
+func sendToMinecraft(ctx context.Context, cfg Config, msg string) error {
+	sanitized := sanitizeChatText(msg)
+	if sanitized == "" {
+		return errors.New("empty response")
+	}
+	filtered, err := cfg.OutputFilter.Check(sanitized)
 	if err != nil {
-		return err
+		log.Printf("[FILTER] %v; sending safe fallback", err)
+		return announce(ctx, cfg, cfg.SafeFallback)
 	}
-	defer file.Close()
-	if _, err := file.Write(append(data, '\n')); err != nil {
-		return err
+	return announce(ctx, cfg, filtered)
+}
+
+// announce broadcasts a line without the output filter. It is for text the server owner
+// configured (support messages, notices, the safe fallback) or that is hard-coded: those
+// words were vetted by a person, and a crisis line's phone number must reach the player
+// intact instead of being rejected as personal data.
+func announce(ctx context.Context, cfg Config, msg string) error {
+	sanitized := sanitizeChatText(msg)
+	if sanitized == "" {
+		return errors.New("empty message")
 	}
-	return nil
+	say := fmt.Sprintf("say [%s] %s", cfg.RobotName, sanitized)
+	_, err := runConsoleCommand(ctx, cfg, say)
+	return err
 }
 
-// sendToMinecraft sanitizes the final response and stuffs it into the screen session.
-// It protects against accidental multi-line posts that could break the console layout.
-func sendToMinecraft(ctx context.Context, cfg Config, msg string) error {
-	sanitized := strings.ReplaceAll(msg, "\n", " ")
-	sanitized = strings.TrimSpace(sanitized)
+// tellPlayer whispers msg to a single player with /tell, for replies such as admin
+// command output that nobody else should see.
+func tellPlayer(ctx context.Context, cfg Config, player, msg string) error {
+	player, err := validatePlayerName(player)
+	if err != nil {
+		return err
+	}
+	sanitized := sanitizeChatText(msg)
 	if sanitized == "" {
-		return errors.New("empty response")
+		return errors.New("empty message")
 	}
-	say := fmt.Sprintf("say [%s] %s\r", cfg.RobotName, sanitized)
-	return runScreenCommand(ctx, cfg, say)
+	_, err = runConsoleCommand(ctx, cfg, fmt.Sprintf("tell %s [%s] %s", player, cfg.RobotName, sanitized))
+	return err
 }
 
 // Tool definitions follow: each describes a fun or utility action Alfred may request.
 // teleportToolDefinition describes the utility that moves one camper to another.
 // It is the most common helper, so it stays enabled whenever tool use is allowed.
-func teleportToolDefinition() ToolDefinition {
+// Named destinations are listed as an enum so the model only picks real places.
+func teleportToolDefinition(waypoints []string) ToolDefinition {
+	destinations := append([]string{"spawn", "home"}, waypoints...)
 	return ToolDefinition{
 		Type: "function",
 		Function: ToolFunctionDefinition{
@@ -320,7 +460,7 @@ func teleportToolDefinition() ToolDefinition {
 				"properties": map[string]interface{}{
 					"target_player": map[string]interface{}{
 						"type":        "string",
-						"description": "Exact username the requester wants to teleport to.",
+						"description": "Username the requester wants to teleport to; must be online (close spellings are matched). The target is asked to accept first.",
 					},
 					"coordinates": map[string]interface{}{
 						"type":        "object",
@@ -343,7 +483,8 @@ func teleportToolDefinition() ToolDefinition {
 					},
 					"destination": map[string]interface{}{
 						"type":        "string",
-						"description": "Use 'spawn' to send them to the configured spawn point.",
+						"enum":        destinations,
+						"description": "A named place: 'spawn' for the spawn point, 'home' for the requester's saved home, or a camp waypoint.",
 					},
 					"from_player": map[string]interface{}{
 						"type":        "string",
@@ -618,7 +759,7 @@ func parseTeleportArgs(raw string) (teleportArguments, error) {
 	}
 	payload.TargetPlayer = strings.TrimSpace(payload.TargetPlayer)
 	payload.FromPlayer = strings.TrimSpace(payload.FromPlayer)
-	payload.Destination = strings.ToLower(strings.TrimSpace(payload.Destination))
+	payload.Destination = strings.TrimSpace(payload.Destination)
 	return payload, nil
 }
 
@@ -628,18 +769,23 @@ const (
 	teleportTargetPlayer teleportTargetKind = iota + 1
 	teleportTargetCoordinates
 	teleportTargetSpawn
+	teleportTargetWaypoint
 )
 
 type teleportTarget struct {
-	kind   teleportTargetKind
-	player string
-	coords coordinateArguments
+	kind     teleportTargetKind
+	player   string
+	coords   coordinateArguments
+	waypoint string
 }
 
 func resolveTeleportTarget(args teleportArguments) (teleportTarget, error) {
+	// The schema check accepts enum values in any case ("Spawn", "HOME"), so the
+	// destination is normalized before it is compared.
+	destination := strings.ToLower(strings.TrimSpace(args.Destination))
 	hasPlayer := args.TargetPlayer != ""
 	hasCoords := args.Coordinates != nil
-	hasDestination := args.Destination != ""
+	hasDestination := destination != ""
 
 	count := 0
 	if hasPlayer {
@@ -659,10 +805,10 @@ func resolveTeleportTarget(args teleportArguments) (teleportTarget, error) {
 		return teleportTarget{}, errors.New("choose a single teleport target (player, coordinates, or spawn)")
 	}
 	if hasDestination {
-		if args.Destination != "spawn" {
-			return teleportTarget{}, fmt.Errorf("unsupported destination: %s", args.Destination)
+		if destination == "spawn" {
+			return teleportTarget{kind: teleportTargetSpawn}, nil
 		}
-		return teleportTarget{kind: teleportTargetSpawn}, nil
+		return teleportTarget{kind: teleportTargetWaypoint, waypoint: destination}, nil
 	}
 	if hasCoords {
 		if err := validateCoordinates(*args.Coordinates); err != nil {
@@ -712,22 +858,19 @@ func parseWeatherArgs(raw string) (string, error) {
 }
 
 // parsePlayerArg resolves the player field for any optional Easter egg helper.
-// It falls back to the speaking camper so Alfred always has a valid target.
-func parsePlayerArg(raw string, fallback string) (string, error) {
-	player := strings.TrimSpace(fallback)
+// It falls back to the speaking camper so Alfred always has a valid target; a name the
+// model supplies must match someone on the online roster.
+func parsePlayerArg(cfg Config, raw string, fallback string) (string, error) {
 	if strings.TrimSpace(raw) != "" {
 		var payload playerArguments
 		if err := json.Unmarshal([]byte(raw), &payload); err != nil {
 			return "", err
 		}
 		if candidate := strings.TrimSpace(payload.Player); candidate != "" {
-			player = candidate
+			return cfg.Roster.Resolve(candidate)
 		}
 	}
-	if player == "" {
-		return "", errors.New("missing player")
-	}
-	return player, nil
+	return validatePlayerName(fallback)
 }
 
 // sanitizeTimeValue enforces “day|noon|night|midnight” or tick values within range.
@@ -762,54 +905,6 @@ func sanitizeWeatherState(raw string) (string, error) {
 	return "", fmt.Errorf("unsupported weather value: %s", raw)
 }
 
-// availableTooling assembles the tool definitions and executors according to config.
-// It keeps the rest of the bot ignorant of which helpers are actually enabled.
-func availableTooling(cfg Config) ([]ToolDefinition, map[string]ToolExecutor) {
-	if !cfg.EnableToolUse {
-		return nil, nil
-	}
-	// Teleport is always available when tool use is on.
-	tools := []ToolDefinition{teleportToolDefinition()}
-	executors := map[string]ToolExecutor{
-		teleportToolName: executeTeleportTool,
-	}
-	if cfg.EnableWorldTool {
-		// Time/weather controls bolt onto the base tool list.
-		tools = append(tools, timeToolDefinition(), weatherToolDefinition())
-		executors[timeToolName] = executeTimeTool
-		executors[weatherToolName] = executeWeatherTool
-	}
-	if cfg.EnableEasterEggs {
-		eggTools := []ToolDefinition{
-			floatingCatToolDefinition(),
-			tinySlimeToolDefinition(),
-			skyliftToolDefinition(),
-			cookieDropToolDefinition(),
-			villagerHmmToolDefinition(),
-			fireworkToolDefinition(),
-			glowAuraToolDefinition(),
-			heartParticlesToolDefinition(),
-			poofToolDefinition(),
-			golemGuardToolDefinition(),
-		}
-		tools = append(tools, eggTools...)
-		executors[floatingCatToolName] = executeFloatingCatTool
-		executors[tinySlimeToolName] = executeTinySlimeTool
-		executors[skyliftToolName] = executeSkyliftTool
-		executors[cookieDropToolName] = executeCookieDropTool
-		executors[villagerHmmToolName] = executeVillagerHmmTool
-		executors[fireworkToolName] = executeFireworkTool
-		executors[glowAuraToolName] = executeGlowAuraTool
-		executors[heartsToolName] = executeHeartParticlesTool
-		executors[poofToolName] = executePoofTool
-		executors[golemGuardToolName] = executeGolemGuardTool
-	}
-	if len(tools) == 0 {
-		return nil, nil
-	}
-	return tools, executors
-}
-
 // executeTeleportTool moves only the requesting camper to another player when teleport_player is invoked.
 // It blocks attempts to teleport third parties without their own request.
 func executeTeleportTool(ctx context.Context, cfg Config, evt ChatEvent, call ToolCall) (string, error) {
@@ -830,27 +925,47 @@ func executeTeleportTool(ctx context.Context, cfg Config, evt ChatEvent, call To
 		return "", err
 	}
 
-	var destLabel string
+	var (
+		destLabel string
+		reply     string
+	)
 	switch target.kind {
 	case teleportTargetPlayer:
-		if err := teleportPlayer(ctx, cfg, from, target.player); err != nil {
+		to, err := cfg.Roster.Resolve(target.player)
+		if err != nil {
 			return "", err
 		}
-		destLabel = target.player
+		// 🎓 LEARNING NOTE: Visiting another camper needs their OK first (staff skip the knock)
+		if cfg.Consent != nil && !isStaff(cfg, from) {
+			return requestTeleport(ctx, cfg, from, to)
+		}
+		if reply, err = teleportPlayer(ctx, cfg, from, to); err != nil {
+			return "", err
+		}
+		destLabel = to
 	case teleportTargetCoordinates:
-		if err := teleportToCoordinates(ctx, cfg, from, target.coords); err != nil {
+		if reply, err = teleportToCoordinates(ctx, cfg, from, target.coords); err != nil {
 			return "", err
 		}
 		destLabel = coordinateLabel(target.coords)
 	case teleportTargetSpawn:
-		if err := teleportToSpawn(ctx, cfg, from); err != nil {
+		if reply, err = teleportToSpawn(ctx, cfg, from); err != nil {
 			return "", err
 		}
 		destLabel = "spawn point"
+	case teleportTargetWaypoint:
+		wp, label, err := lookupDestination(cfg, from, target.waypoint)
+		if err != nil {
+			return "", err
+		}
+		if reply, err = teleportToWaypoint(ctx, cfg, from, wp); err != nil {
+			return "", err
+		}
+		destLabel = label
 	default:
 		return "", errors.New("unsupported teleport target")
 	}
-	return fmt.Sprintf("Teleported %s to %s", from, destLabel), nil
+	return withConsoleReply(fmt.Sprintf("Teleported %s to %s", from, destLabel), reply), nil
 }
 
 // executeTimeTool sends `time set` commands for the set_time helper.
@@ -860,12 +975,13 @@ func executeTimeTool(ctx context.Context, cfg Config, evt ChatEvent, call ToolCa
 	if err != nil {
 		return "", err
 	}
-	command := fmt.Sprintf("time set %s\r", value)
+	command := fmt.Sprintf("time set %s", value)
 	log.Printf("[BOT] Setting time to %s per request from %s", value, evt.Player)
-	if err := runScreenCommand(ctx, cfg, command); err != nil {
+	reply, err := runConsoleCommand(ctx, cfg, command)
+	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("World time set to %s.", value), nil
+	return withConsoleReply(fmt.Sprintf("World time set to %s.", value), reply), nil
 }
 
 // executeWeatherTool handles set_weather requests from the LLM.
@@ -875,156 +991,166 @@ func executeWeatherTool(ctx context.Context, cfg Config, evt ChatEvent, call Too
 	if err != nil {
 		return "", err
 	}
-	command := fmt.Sprintf("weather %s\r", state)
+	command := fmt.Sprintf("weather %s", state)
 	log.Printf("[BOT] Setting weather to %s per request from %s", state, evt.Player)
-	if err := runScreenCommand(ctx, cfg, command); err != nil {
+	reply, err := runConsoleCommand(ctx, cfg, command)
+	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("Weather set to %s.", state), nil
+	return withConsoleReply(fmt.Sprintf("Weather set to %s.", state), reply), nil
 }
 
 // executeFloatingCatTool conjures the floating familiar for the chosen camper.
 // The summoned cat has NoAI/NoGravity so it remains a pure cosmetic moment.
 func executeFloatingCatTool(ctx context.Context, cfg Config, evt ChatEvent, call ToolCall) (string, error) {
-	player, err := parsePlayerArg(call.Function.Arguments, evt.Player)
+	player, err := parsePlayerArg(cfg, call.Function.Arguments, evt.Player)
 	if err != nil {
 		return "", err
 	}
-	command := fmt.Sprintf("execute at %s run summon cat ~ ~1 ~ {NoAI:1b,NoGravity:1b,Silent:1b}\r", player)
+	command := fmt.Sprintf("execute at %s run summon cat ~ ~1 ~ {NoAI:1b,NoGravity:1b,Silent:1b}", player)
 	log.Printf("[BOT] Summoning floating cat near %s", player)
-	if err := runScreenCommand(ctx, cfg, command); err != nil {
+	reply, err := runConsoleCommand(ctx, cfg, command)
+	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("Summoned a floating cat near %s.", player), nil
+	return withConsoleReply(fmt.Sprintf("Summoned a floating cat near %s.", player), reply), nil
 }
 
 // executeTinySlimeTool spawns an idle slime friend around the player.
 // The slime is equally harmless, sitting still and silently wobbling.
 func executeTinySlimeTool(ctx context.Context, cfg Config, evt ChatEvent, call ToolCall) (string, error) {
-	player, err := parsePlayerArg(call.Function.Arguments, evt.Player)
+	player, err := parsePlayerArg(cfg, call.Function.Arguments, evt.Player)
 	if err != nil {
 		return "", err
 	}
-	command := fmt.Sprintf("execute at %s run summon slime ~ ~1 ~ {Size:0,NoAI:1b,Silent:1b}\r", player)
+	command := fmt.Sprintf("execute at %s run summon slime ~ ~1 ~ {Size:0,NoAI:1b,Silent:1b}", player)
 	log.Printf("[BOT] Spawning tiny slime near %s", player)
-	if err := runScreenCommand(ctx, cfg, command); err != nil {
+	reply, err := runConsoleCommand(ctx, cfg, command)
+	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("Tiny slime summoned near %s.", player), nil
+	return withConsoleReply(fmt.Sprintf("Tiny slime summoned near %s.", player), reply), nil
 }
 
 // executeSkyliftTool applies slow falling and teleports the camper skyward.
-// Two commands run in sequence, so runScreenBatch ensures both fire or the error bubbles up.
+// Two commands run in sequence, so runConsoleBatch ensures both fire or the error bubbles up.
 func executeSkyliftTool(ctx context.Context, cfg Config, evt ChatEvent, call ToolCall) (string, error) {
-	player, err := parsePlayerArg(call.Function.Arguments, evt.Player)
+	player, err := parsePlayerArg(cfg, call.Function.Arguments, evt.Player)
 	if err != nil {
 		return "", err
 	}
 	commands := []string{
-		fmt.Sprintf("effect give %s slow_falling 10 0 true\r", player),
-		fmt.Sprintf("tp %s ~ 200 ~\r", player),
+		fmt.Sprintf("effect give %s slow_falling 10 0 true", player),
+		fmt.Sprintf("tp %s ~ 200 ~", player),
 	}
 	log.Printf("[BOT] Launching skylift for %s", player)
-	if err := runScreenBatch(ctx, cfg, commands); err != nil {
+	reply, err := runConsoleBatch(ctx, cfg, commands)
+	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("%s lifted sky-high with slow falling.", player), nil
+	return withConsoleReply(fmt.Sprintf("%s lifted sky-high with slow falling.", player), reply), nil
 }
 
 // executeCookieDropTool drops a cookie item just above the target.
 // It is a tiny morale boost that never affects gameplay balance.
 func executeCookieDropTool(ctx context.Context, cfg Config, evt ChatEvent, call ToolCall) (string, error) {
-	player, err := parsePlayerArg(call.Function.Arguments, evt.Player)
+	player, err := parsePlayerArg(cfg, call.Function.Arguments, evt.Player)
 	if err != nil {
 		return "", err
 	}
-	command := fmt.Sprintf("execute at %s run summon item ~ ~1 ~ {Item:{id:\"minecraft:cookie\",Count:1b}}\r", player)
+	command := fmt.Sprintf("execute at %s run summon item ~ ~1 ~ {Item:{id:\"minecraft:cookie\",Count:1b}}", player)
 	log.Printf("[BOT] Dropping cookie for %s", player)
-	if err := runScreenCommand(ctx, cfg, command); err != nil {
+	reply, err := runConsoleCommand(ctx, cfg, command)
+	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("Cookie dropped for %s.", player), nil
+	return withConsoleReply(fmt.Sprintf("Cookie dropped for %s.", player), reply), nil
 }
 
 // executeVillagerHmmTool plays the ambient villager sound at the camper's location.
 // Alfred uses it sparingly to preserve the comedic timing.
 func executeVillagerHmmTool(ctx context.Context, cfg Config, evt ChatEvent, call ToolCall) (string, error) {
-	player, err := parsePlayerArg(call.Function.Arguments, evt.Player)
+	player, err := parsePlayerArg(cfg, call.Function.Arguments, evt.Player)
 	if err != nil {
 		return "", err
 	}
-	command := fmt.Sprintf("playsound minecraft:entity.villager.ambient player %s ~ ~ ~ 1\r", player)
+	command := fmt.Sprintf("playsound minecraft:entity.villager.ambient player %s ~ ~ ~ 1", player)
 	log.Printf("[BOT] Playing villager hmm near %s", player)
-	if err := runScreenCommand(ctx, cfg, command); err != nil {
+	reply, err := runConsoleCommand(ctx, cfg, command)
+	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("Played villager hmm near %s.", player), nil
+	return withConsoleReply(fmt.Sprintf("Played villager hmm near %s.", player), reply), nil
 }
 
 // executeFireworkTool spawns a low-altitude rocket for celebrations.
 // The rocket lifetime is short so it never damages structures or players.
 func executeFireworkTool(ctx context.Context, cfg Config, evt ChatEvent, call ToolCall) (string, error) {
-	player, err := parsePlayerArg(call.Function.Arguments, evt.Player)
+	player, err := parsePlayerArg(cfg, call.Function.Arguments, evt.Player)
 	if err != nil {
 		return "", err
 	}
-	command := fmt.Sprintf("execute at %s run summon firework_rocket ~ ~1 ~ {LifeTime:20}\r", player)
+	command := fmt.Sprintf("execute at %s run summon firework_rocket ~ ~1 ~ {LifeTime:20}", player)
 	log.Printf("[BOT] Launching mini firework for %s", player)
-	if err := runScreenCommand(ctx, cfg, command); err != nil {
+	reply, err := runConsoleCommand(ctx, cfg, command)
+	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("Mini firework launched for %s.", player), nil
+	return withConsoleReply(fmt.Sprintf("Mini firework launched for %s.", player), reply), nil
 }
 
 // executeGlowAuraTool grants the glowing effect to highlight a camper briefly.
 // Because it is purely visual, campers get a “blessing” without gameplay changes.
 func executeGlowAuraTool(ctx context.Context, cfg Config, evt ChatEvent, call ToolCall) (string, error) {
-	player, err := parsePlayerArg(call.Function.Arguments, evt.Player)
+	player, err := parsePlayerArg(cfg, call.Function.Arguments, evt.Player)
 	if err != nil {
 		return "", err
 	}
-	command := fmt.Sprintf("effect give %s minecraft:glowing 20 0 true\r", player)
+	command := fmt.Sprintf("effect give %s minecraft:glowing 20 0 true", player)
 	log.Printf("[BOT] Granting glow aura to %s", player)
-	if err := runScreenCommand(ctx, cfg, command); err != nil {
+	reply, err := runConsoleCommand(ctx, cfg, command)
+	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("%s is now glowing briefly.", player), nil
+	return withConsoleReply(fmt.Sprintf("%s is now glowing briefly.", player), reply), nil
 }
 
 // executeHeartParticlesTool surrounds the player with heart particles.
 // Alfred leans on it when reinforcing kindness or celebrating teamwork.
 func executeHeartParticlesTool(ctx context.Context, cfg Config, evt ChatEvent, call ToolCall) (string, error) {
-	player, err := parsePlayerArg(call.Function.Arguments, evt.Player)
+	player, err := parsePlayerArg(cfg, call.Function.Arguments, evt.Player)
 	if err != nil {
 		return "", err
 	}
-	command := fmt.Sprintf("execute at %s run particle minecraft:heart ~ ~1 ~ 0.3 0.3 0.3 0 20\r", player)
+	command := fmt.Sprintf("execute at %s run particle minecraft:heart ~ ~1 ~ 0.3 0.3 0.3 0 20", player)
 	log.Printf("[BOT] Sending heart particles around %s", player)
-	if err := runScreenCommand(ctx, cfg, command); err != nil {
+	reply, err := runConsoleCommand(ctx, cfg, command)
+	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("Heart sparkle burst for %s.", player), nil
+	return withConsoleReply(fmt.Sprintf("Heart sparkle burst for %s.", player), reply), nil
 }
 
 // executePoofTool generates a poof cloud for comedic timing or transitions.
 // Like other particles, it runs via `execute at` so it follows the player accurately.
 func executePoofTool(ctx context.Context, cfg Config, evt ChatEvent, call ToolCall) (string, error) {
-	player, err := parsePlayerArg(call.Function.Arguments, evt.Player)
+	player, err := parsePlayerArg(cfg, call.Function.Arguments, evt.Player)
 	if err != nil {
 		return "", err
 	}
-	command := fmt.Sprintf("execute at %s run particle poof ~ ~1 ~ 0.3 0.3 0.3 0 15\r", player)
+	command := fmt.Sprintf("execute at %s run particle poof ~ ~1 ~ 0.3 0.3 0.3 0 15", player)
 	log.Printf("[BOT] Creating poof of smoke near %s", player)
-	if err := runScreenCommand(ctx, cfg, command); err != nil {
+	reply, err := runConsoleCommand(ctx, cfg, command)
+	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("Poof of smoke near %s.", player), nil
+	return withConsoleReply(fmt.Sprintf("Poof of smoke near %s.", player), reply), nil
 }
 
 // executeGolemGuardTool drops a friendly iron golem beside the camper for protection.
 // The golem is marked PlayerCreated so it behaves like a normal guardian ally.
 func executeGolemGuardTool(ctx context.Context, cfg Config, evt ChatEvent, call ToolCall) (string, error) {
-	player, err := parsePlayerArg(call.Function.Arguments, evt.Player)
+	player, err := parsePlayerArg(cfg, call.Function.Arguments, evt.Player)
 	if err != nil {
 		return "", err
 	}
@@ -1037,13 +1163,14 @@ func executeGolemGuardTool(ctx context.Context, cfg Config, evt ChatEvent, call
 // triggerSafeLightning summons a lightning bolt a few blocks in front of a player.
 // Moderation paths use it to add drama when kindness reminders are triggered.
 func triggerSafeLightning(ctx context.Context, cfg Config, player string) error {
-	player = strings.TrimSpace(player)
-	if player == "" {
-		return errors.New("missing player for lightning strike")
+	player, err := validatePlayerName(player)
+	if err != nil {
+		return fmt.Errorf("lightning strike: %w", err)
 	}
-	command := fmt.Sprintf("execute at %s run summon lightning_bolt ^ ^ ^3\r", player)
+	command := fmt.Sprintf("execute at %s run summon lightning_bolt ^ ^ ^3", player)
 	log.Printf("[BOT] Triggering safe lightning near %s", player)
-	return runScreenCommand(ctx, cfg, command)
+	_, err = runConsoleCommand(ctx, cfg, command)
+	return err
 }
 
 func coordinateLabel(coords coordinateArguments) string {
@@ -1056,53 +1183,78 @@ func formatCoordinate(value float64) string {
 
 // teleportPlayer wraps the basic /tp command for reuse by tool executors.
 // Keeping it centralized simplifies future logging or safety checks.
-func teleportPlayer(ctx context.Context, cfg Config, from, to string) error {
-	command := fmt.Sprintf("tp %s %s\r", from, to)
-	return runScreenCommand(ctx, cfg, command)
+func teleportPlayer(ctx context.Context, cfg Config, from, to string) (string, error) {
+	from, err := validatePlayerName(from)
+	if err != nil {
+		return "", err
+	}
+	if to, err = validatePlayerName(to); err != nil {
+		return "", err
+	}
+	command := fmt.Sprintf("tp %s %s", from, to)
+	return runConsoleCommand(ctx, cfg, command)
 }
 
-func teleportToCoordinates(ctx context.Context, cfg Config, player string, coords coordinateArguments) error {
-	command := fmt.Sprintf("tp %s %s\r", player, coordinateLabel(coords))
-	return runScreenCommand(ctx, cfg, command)
+func teleportToCoordinates(ctx context.Context, cfg Config, player string, coords coordinateArguments) (string, error) {
+	player, err := validatePlayerName(player)
+	if err != nil {
+		return "", err
+	}
+	command := fmt.Sprintf("tp %s %s", player, coordinateLabel(coords))
+	return runConsoleCommand(ctx, cfg, command)
 }
 
-func teleportToSpawn(ctx context.Context, cfg Config, player string) error {
-	command := fmt.Sprintf("execute in %s run tp %s %s\r", cfg.SpawnDimension, player, coordinateLabel(coordinateArguments{
-		X: cfg.SpawnPoint[0],
-		Y: cfg.SpawnPoint[1],
-		Z: cfg.SpawnPoint[2],
-	}))
-	return runScreenCommand(ctx, cfg, command)
+func teleportToSpawn(ctx context.Context, cfg Config, player string) (string, error) {
+	return teleportToWaypoint(ctx, cfg, player, waypoint{
+		X:         cfg.SpawnPoint[0],
+		Y:         cfg.SpawnPoint[1],
+		Z:         cfg.SpawnPoint[2],
+		Dimension: cfg.SpawnDimension,
+	})
 }
 
 // summonGolemGuard spawns a sturdy iron golem next to the given player.
 // The guard is persistent and player-created so it behaves as a friendly ally.
 func summonGolemGuard(ctx context.Context, cfg Config, player string) error {
-	player = strings.TrimSpace(player)
-	if player == "" {
-		return errors.New("missing player for golem guard")
+	player, err := validatePlayerName(player)
+	if err != nil {
+		return fmt.Errorf("golem guard: %w", err)
 	}
-	command := fmt.Sprintf("execute at %s run summon iron_golem ~ ~1 ~ {PersistenceRequired:1b,PlayerCreated:1b}\r", player)
+	command := fmt.Sprintf("execute at %s run summon iron_golem ~ ~1 ~ {PersistenceRequired:1b,PlayerCreated:1b}", player)
 	log.Printf("[BOT] Summoning golem guard near %s", player)
-	return runScreenCommand(ctx, cfg, command)
+	_, err = runConsoleCommand(ctx, cfg, command)
+	return err
 }
 
-// runScreenCommand injects a single payload into the configured screen session.
-// All console interactions funnel through this helper to keep side effects predictable.
-func runScreenCommand(ctx context.Context, cfg Config, payload string) error {
-	cmd := exec.CommandContext(ctx, "screen", "-S", cfg.ScreenSession, "-p", "0", "-X", "stuff", payload)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// runConsoleCommand sends a single command through the configured transport and returns
+// the server's reply. All console interactions funnel through this helper, and replies that
+// signal failure ("No player was found") are surfaced as errors.
+func runConsoleCommand(ctx context.Context, cfg Config, command string) (string, error) {
+	if cfg.Console == nil {
+		return "", errors.New("no console transport configured")
+	}
+	reply, err := cfg.Console.Run(ctx, command)
+	if err != nil {
+		return "", err
+	}
+	if err := consoleReplyError(reply); err != nil {
+		return "", err
+	}
+	return reply, nil
 }
 
-// runScreenBatch executes multiple commands sequentially, aborting on the first failure.
+// runConsoleBatch executes multiple commands sequentially, aborting on the first failure.
 // It is primarily used for skylift where multiple console writes must succeed together.
-func runScreenBatch(ctx context.Context, cfg Config, commands []string) error {
+func runConsoleBatch(ctx context.Context, cfg Config, commands []string) (string, error) {
+	var replies []string
 	for _, cmd := range commands {
-		if err := runScreenCommand(ctx, cfg, cmd); err != nil {
-			return err
+		reply, err := runConsoleCommand(ctx, cfg, cmd)
+		if err != nil {
+			return "", err
+		}
+		if reply != "" {
+			replies = append(replies, reply)
 		}
 	}
-	return nil
+	return strings.Join(replies, " "), nil
 }
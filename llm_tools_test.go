@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedProvider answers chat requests from a fixed script, one response per call,
+// and keeps every request so tests can inspect what the model was sent.
+type scriptedProvider struct {
+	mu        sync.Mutex
+	responses []Message
+	requests  []ChatRequest
+}
+
+func (p *scriptedProvider) Complete(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, req)
+	if len(p.responses) == 0 {
+		return ChatResponse{Choices: []Choice{{Message: Message{Role: "assistant", Content: "All done!"}}}}, nil
+	}
+	msg := p.responses[0]
+	p.responses = p.responses[1:]
+	if msg.Role == "" {
+		msg.Role = "assistant"
+	}
+	return ChatResponse{Choices: []Choice{{Message: msg}}}, nil
+}
+
+func testModeration(t *testing.T) *moderationEngine {
+	t.Helper()
+	engine, err := newModerationEngine(nil, nil, "")
+	if err != nil {
+		t.Fatalf("moderation engine: %v", err)
+	}
+	return engine
+}
+
+func TestCallLLMRemembersFilteredReply(t *testing.T) {
+	memory, err := newConversationMemory(4, 0, 0, time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &scriptedProvider{responses: []Message{
+		{Content: "Email me at counselor@example.com and I'll send the map!"},
+		{Content: "Follow the torches north to find the lodge."},
+	}}
+	cfg := Config{
+		LLM:           provider,
+		Memory:        memory,
+		OutputFilter:  newOutputFilter(30, testModeration(t), nil),
+		SafeFallback:  "Let's keep building!",
+		ReplyMaxWords: 30,
+	}
+	evt := ChatEvent{Kind: EventChat, Player: "Steve", Text: "where is the lodge?"}
+
+	resp, logs, err := callLLM(context.Background(), cfg, evt, evt.Text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != "Follow the torches north to find the lodge." {
+		t.Fatalf("reply = %q", resp)
+	}
+	if len(logs) != 1 || logs[0].Name != "output_filter" {
+		t.Fatalf("logs = %+v, want the rejected draft", logs)
+	}
+	recalled := memory.Recall("Steve", time.Now())
+	for _, msg := range recalled {
+		if strings.Contains(msg.Content, "@example.com") {
+			t.Fatalf("memory kept the rejected draft: %q", msg.Content)
+		}
+	}
+	if last := recalled[len(recalled)-1]; last.Content != resp {
+		t.Fatalf("memory ends with %q, want the posted reply %q", last.Content, resp)
+	}
+}
+
+func TestChatWithToolsRunsRepeatedCallOnce(t *testing.T) {
+	firework := ToolCallFunction{Name: fireworkToolName, Arguments: `{}`}
+	glow := ToolCallFunction{Name: glowAuraToolName, Arguments: `{}`}
+	provider := &scriptedProvider{responses: []Message{{ToolCalls: []ToolCall{
+		{ID: "a1", Type: "function", Function: firework},
+		{ID: "a2", Type: "function", Function: firework},
+		{ID: "b1", Type: "function", Function: glow},
+	}}}}
+	console := &fakeConsole{}
+	cfg := Config{LLM: provider, Console: console, EnableToolUse: true, EnableEasterEggs: true, ToolHops: 3}
+	registry, err := newToolRegistry(cfg, builtinTools()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Tools = registry
+	evt := ChatEvent{Kind: EventChat, Player: "Steve", Text: "fireworks and glow please"}
+
+	_, logs, _, err := chatWithTools(context.Background(), cfg, evt, []Message{{Role: "user", Content: evt.Text}}, registry.Available(cfg, "Steve"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commands := console.sent(); len(commands) != 2 {
+		t.Fatalf("console commands = %q, want the firework and the glow once each", commands)
+	}
+	if len(logs) != 2 || logs[0].Name != fireworkToolName || logs[1].Name != glowAuraToolName {
+		t.Fatalf("tool logs = %+v", logs)
+	}
+	results := map[string]string{}
+	for _, msg := range provider.requests[1].Messages {
+		if msg.Role == "tool" {
+			results[msg.ToolCallID] = msg.Content
+		}
+	}
+	if !strings.HasPrefix(results["a1"], "Mini firework") || !strings.Contains(results["a2"], "already ran") || !strings.Contains(results["b1"], "glowing") {
+		t.Fatalf("tool results = %q, want each call ID matched to its own result", results)
+	}
+}
+
+func TestTeleportDestinationIgnoresCase(t *testing.T) {
+	waypoints, err := newWaypointStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := waypoints.Set("lodge", waypoint{X: 10, Y: 70, Z: -5, Dimension: "minecraft:overworld"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := waypoints.SetHome("Steve", waypoint{X: 1, Y: 64, Z: 2, Dimension: "minecraft:overworld"}); err != nil {
+		t.Fatal(err)
+	}
+	console := &fakeConsole{}
+	cfg := Config{
+		Console:        console,
+		EnableToolUse:  true,
+		Waypoints:      waypoints,
+		SpawnPoint:     [3]float64{0, 80, 0},
+		SpawnDimension: "minecraft:overworld",
+	}
+	registry, err := newToolRegistry(cfg, builtinTools()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offered := registry.Available(cfg, "Steve")
+	cases := []struct {
+		destination string
+		command     string
+	}{
+		{"Spawn", "execute in minecraft:overworld run tp Steve 0 80 0"},
+		{" SPAWN ", "execute in minecraft:overworld run tp Steve 0 80 0"},
+		{"HOME", "execute in minecraft:overworld run tp Steve 1 64 2"},
+		{"Lodge", "execute in minecraft:overworld run tp Steve 10 70 -5"},
+	}
+	for _, tc := range cases {
+		call := ToolCall{Function: ToolCallFunction{Name: teleportToolName, Arguments: `{"destination":"` + tc.destination + `"}`}}
+		inv := registry.Run(context.Background(), cfg, ChatEvent{Player: "Steve"}, offered, call)
+		if inv.Error != "" {
+			t.Errorf("destination %q failed: %s", tc.destination, inv.Error)
+			continue
+		}
+		commands := console.sent()
+		if last := commands[len(commands)-1]; last != tc.command {
+			t.Errorf("destination %q ran %q, want %q", tc.destination, last, tc.command)
+		}
+	}
+}
+
+func TestResolveTeleportTargetIgnoresCase(t *testing.T) {
+	for destination, want := range map[string]teleportTarget{
+		"Spawn":   {kind: teleportTargetSpawn},
+		" SPAWN ": {kind: teleportTargetSpawn},
+		"HOME":    {kind: teleportTargetWaypoint, waypoint: "home"},
+		"Lodge":   {kind: teleportTargetWaypoint, waypoint: "lodge"},
+	} {
+		got, err := resolveTeleportTarget(teleportArguments{Destination: destination})
+		if err != nil || got != want {
+			t.Errorf("resolveTeleportTarget(%q) = %+v, %v; want %+v", destination, got, err, want)
+		}
+	}
+	waypoints, _ := newWaypointStore("")
+	waypoints.SetHome("Steve", waypoint{X: 1, Y: 64, Z: 2, Dimension: "minecraft:overworld"})
+	if _, label, err := lookupDestination(Config{Waypoints: waypoints}, "Steve", "HoMe"); err != nil || label != "home" {
+		t.Errorf("lookupDestination(HoMe) = %q, %v; want Steve's home", label, err)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// logFormatAuto asks watchChat to sniff the flavor from the top of latest.log.
+const logFormatAuto = "auto"
+
+// logDetectLines bounds how much of latest.log is scanned when auto-detecting.
+const logDetectLines = 200
+
+// logFormat describes how one server flavor lays out latest.log. Each flavor wraps the
+// same vanilla messages in a slightly different header, and plugin servers move chat
+// onto a dedicated async thread where rank prefixes are common.
+type logFormat struct {
+	Name string
+	// header splits a line into thread name and message.
+	header *regexp.Regexp
+	// chatThread matches the thread names that carry player chat.
+	chatThread *regexp.Regexp
+	// rankedChat enables plugin chat layouts such as "[Admin] Steve: hi".
+	rankedChat bool
+	// detect lists startup banner fragments that only this flavor prints.
+	detect []string
+}
+
+var (
+	// vanillaHeader covers "[12:34:56] [Server thread/INFO]: message" (vanilla, Spigot, Paper).
+	vanillaHeader = regexp.MustCompile(`^\[[^\]]+\] \[([^\]]+)/[A-Z]+\]: (.*)$`)
+	// fabricHeader covers "[12:34:56] [Server thread/INFO] (Minecraft) message".
+	fabricHeader = regexp.MustCompile(`^\[[^\]]+\] \[([^\]]+)/[A-Z]+\] \([^)]*\) (.*)$`)
+	// forgeHeader covers "[16Oct2026 12:34:56.789] [Server thread/INFO] [net.minecraft.server.MinecraftServer/]: message".
+	forgeHeader = regexp.MustCompile(`^\[[^\]]+\] \[([^\]]+)/[A-Z]+\] \[[^\]]*\]: (.*)$`)
+
+	serverThread     = regexp.MustCompile(`^Server thread$`)
+	pluginChatThread = regexp.MustCompile(`^(?:Async Chat Thread - #\d+|Server thread)$`)
+
+	// angleChatPattern matches "<Steve> hi" and ranked variants like "<[Admin] Steve> hi".
+	angleChatPattern = regexp.MustCompile(`^<(?:[^<>]*[\]\s])?([A-Za-z0-9_]{2,16})> (.*)$`)
+	// rankedChatPattern matches plugin layouts such as "[Admin] Steve: hi" or "[VIP] Steve » hi".
+	rankedChatPattern = regexp.MustCompile(`^(?:[\[(][^\])]*[\])]\s*)*~?([A-Za-z0-9_]{2,16})\s*(?::|»|>)\s+(.*)$`)
+	// colorCodePattern strips legacy section-sign color codes some chat plugins leave behind.
+	colorCodePattern = regexp.MustCompile(`§[0-9a-fk-orA-FK-OR]`)
+)
+
+// logFormats is the registry of supported flavors keyed by config name.
+var logFormats = map[string]logFormat{
+	"vanilla": {
+		Name:       "vanilla",
+		header:     vanillaHeader,
+		chatThread: serverThread,
+	},
+	"spigot": {
+		Name:       "spigot",
+		header:     vanillaHeader,
+		chatThread: pluginChatThread,
+		rankedChat: true,
+		detect:     []string{"This server is running CraftBukkit version"},
+	},
+	"paper": {
+		Name:       "paper",
+		header:     vanillaHeader,
+		chatThread: pluginChatThread,
+		rankedChat: true,
+		detect:     []string{"This server is running Paper version", "This server is running Purpur version"},
+	},
+	"fabric": {
+		Name:       "fabric",
+		header:     fabricHeader,
+		chatThread: serverThread,
+		detect:     []string{"with Fabric Loader"},
+	},
+	"forge": {
+		Name:       "forge",
+		header:     forgeHeader,
+		chatThread: serverThread,
+		detect:     []string{"ModLauncher running", "MinecraftForge v", "NeoForge"},
+	},
+}
+
+// logFormatDetectOrder checks the most specific banners first; Paper also prints
+// "CraftBukkit", so it must win before Spigot is considered.
+var logFormatDetectOrder = []string{"paper", "spigot", "forge", "fabric"}
+
+// lookupLogFormat validates a config value; "auto" is accepted and resolved later.
+func lookupLogFormat(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == logFormatAuto {
+		return logFormatAuto, nil
+	}
+	if _, ok := logFormats[name]; ok {
+		return name, nil
+	}
+	names := make([]string, 0, len(logFormats))
+	for key := range logFormats {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown log format %q (expected auto or one of %s)", name, strings.Join(names, ", "))
+}
+
+// resolveLogFormat returns the configured flavor, or sniffs latest.log when set to auto.
+// Servers write their banner in the first few lines, so a short scan is enough.
+func resolveLogFormat(name, path string) logFormat {
+	if name != logFormatAuto {
+		return logFormats[name]
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return logFormats["paper"]
+	}
+	defer file.Close()
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() && len(lines) < logDetectLines {
+		lines = append(lines, scanner.Text())
+	}
+	return detectLogFormat(lines)
+}
+
+// detectLogFormat picks the flavor whose banner appears first in priority order, then
+// falls back on header shape, and finally on Paper (the historical default).
+func detectLogFormat(lines []string) logFormat {
+	for _, name := range logFormatDetectOrder {
+		format := logFormats[name]
+		for _, line := range lines {
+			if containsFragment(line, format.detect) {
+				return format
+			}
+		}
+	}
+	for _, line := range lines {
+		switch {
+		case forgeHeader.MatchString(line):
+			return logFormats["forge"]
+		case fabricHeader.MatchString(line):
+			return logFormats["fabric"]
+		case strings.Contains(line, "Starting minecraft server version"):
+			return logFormats["vanilla"]
+		}
+	}
+	return logFormats["paper"]
+}
+
+// containsFragment is a case-sensitive banner check; empty fragment lists never match.
+func containsFragment(line string, fragments []string) bool {
+	for _, fragment := range fragments {
+		if fragment != "" && strings.Contains(line, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLine classifies one log line as chat or a server event for this flavor.
+// Chat is tried first so a player typing "joined the game" stays chat.
+func (f logFormat) parseLine(line string) (ChatEvent, bool) {
+	m := f.header.FindStringSubmatch(line)
+	if m == nil {
+		return ChatEvent{}, false
+	}
+	thread, message := m[1], strings.TrimSpace(m[2])
+	if f.chatThread.MatchString(thread) {
+		if evt, ok := f.parseChatMessage(message, thread != "Server thread"); ok {
+			return evt, true
+		}
+	}
+	if thread != "Server thread" {
+		return ChatEvent{}, false
+	}
+	return classifyServerMessage(message)
+}
+
+// parseChatMessage pulls the player and text out of the chat body, tolerating the
+// "[Not Secure]" tag, color codes, and rank prefixes added by plugins like LuckPerms.
+// Ranked layouts without angle brackets are only trusted on dedicated chat threads.
+func (f logFormat) parseChatMessage(message string, chatOnlyThread bool) (ChatEvent, bool) {
+	message = colorCodePattern.ReplaceAllString(message, "")
+	message = strings.TrimSpace(strings.TrimPrefix(message, "[Not Secure]"))
+	if m := angleChatPattern.FindStringSubmatch(message); m != nil {
+		return ChatEvent{Kind: EventChat, Player: m[1], Text: strings.TrimSpace(m[2]), Time: time.Now()}, true
+	}
+	if f.rankedChat && chatOnlyThread {
+		if m := rankedChatPattern.FindStringSubmatch(message); m != nil {
+			return ChatEvent{Kind: EventChat, Player: m[1], Text: strings.TrimSpace(m[2]), Time: time.Now()}, true
+		}
+	}
+	return ChatEvent{}, false
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite testdata golden files from current output")
+
+// TestLogFormatGolden parses every sample under testdata/logformats with the flavor
+// auto-detection picks and compares the events against the matching .golden file.
+// Run `go test -run LogFormatGolden -update` after an intended parser change.
+func TestLogFormatGolden(t *testing.T) {
+	samples := map[string]string{
+		"vanilla":   "vanilla",
+		"spigot":    "spigot",
+		"paper":     "paper",
+		"fabric":    "fabric",
+		"forge":     "forge",
+		"luckperms": "paper",
+	}
+	for sample, wantFormat := range samples {
+		t.Run(sample, func(t *testing.T) {
+			input := filepath.Join("testdata", "logformats", sample+".log")
+			data, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+			format := detectLogFormat(lines)
+			if format.Name != wantFormat {
+				t.Fatalf("detected %q, want %q", format.Name, wantFormat)
+			}
+			var got strings.Builder
+			for _, line := range lines {
+				got.WriteString(describeParsedLine(format, line))
+				got.WriteByte('\n')
+			}
+			golden := strings.TrimSuffix(input, ".log") + ".golden"
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(got.String()), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != string(want) {
+				t.Errorf("parsed events differ from %s\n--- got\n%s--- want\n%s", golden, got.String(), want)
+			}
+		})
+	}
+}
+
+// describeParsedLine renders one parse result as "kind | player | text | detail", or
+// "-" when the line is not an event.
+func describeParsedLine(format logFormat, line string) string {
+	evt, ok := format.parseLine(line)
+	if !ok {
+		return "-"
+	}
+	// Trailing spaces are trimmed so editors that strip them cannot break the golden files.
+	return strings.TrimRight(fmt.Sprintf("%s | %s | %s | %s", evt.Kind, evt.Player, evt.Text, evt.Detail), " ")
+}
@@ -13,18 +13,33 @@ import (
 )
 
 // main bootstraps Alfred: it loads configuration, tails the Minecraft log, routes
-// chat events through the responder logic, and ships final replies into the server.
+// chat and server events through the responder logic, and ships final replies into the server.
 // The loop only exits when the process is interrupted, mirroring a long-running service.
 //
 // 🎓 LEARNING NOTE: This is the "event loop" pattern - it runs forever, listening for
-// events (chat messages) and responding to them. Like a web server, but for Minecraft!
+// events (chat messages, joins, deaths) and responding to them. Like a web server, but for Minecraft!
 func main() {
 	godotenv.Load(".env") // Load secrets from .env file (never commit this file!)
 
+	// 🎓 LEARNING NOTE: `mcchatbot history ...` and `mcchatbot incidents ...` search past
+	// interactions and exit, without starting Alfred or touching the server
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "history":
+			os.Exit(runHistoryCommand(os.Args[2:], os.Stdout, os.Stderr))
+		case "incidents":
+			os.Exit(runIncidentsCommand(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	cfg, err := loadConfig()
 	if err != nil {
 		log.Fatalf("config error: %v", err)
 	}
+	defer cfg.Console.Close()
+	defer cfg.Interactions.Close() // Runs after the workers finish, flushing their last lines
+	defer cfg.History.Close()
+	defer cfg.Transcript.Close()
 
 	// 🎓 LEARNING NOTE: This context allows us to gracefully shut down when you hit Ctrl+C
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
@@ -32,85 +47,243 @@ func main() {
 
 	// 🎓 LEARNING NOTE: Channels are Go's way of passing messages between goroutines
 	// Think of it like a pipe: watchChat writes chat events, main reads them
-	chatCh := make(chan ChatEvent, 10)
+	chatCh := make(chan ChatEvent, cfg.ChatBuffer)
+	stats := &pipelineStats{}
 
 	// 🎓 LEARNING NOTE: "go func()" launches a goroutine (lightweight thread)
 	// This runs in parallel, watching the log file while we process events below
 	go func() {
-		if err := watchChat(ctx, cfg.LogPath, chatCh); err != nil && !errors.Is(err, context.Canceled) {
+		if err := watchChat(ctx, cfg.LogPath, cfg.LogFormat, chatCh, stats); err != nil && !errors.Is(err, context.Canceled) {
 			log.Fatalf("log watcher error: %v", err)
 		}
 	}()
+	go stats.reportLoop(ctx, cfg.MetricsInterval, chatCh)
+	go rosterSyncLoop(ctx, cfg, cfg.RosterSync)
+
+	// 🎓 LEARNING NOTE: Slow AI calls run on worker goroutines so the main loop can keep
+	// reading chat. Each player always lands on the same worker, keeping replies in order
+	pool := newWorkerPool(ctx, cfg.Workers, cfg.WorkerQueue, stats)
+	defer pool.Wait()
 
 	log.Printf("Alfred ready. Watching %s", cfg.LogPath)
 
-	var lastReply time.Time // Track when we last spoke (for rate limiting)
+	// 🎓 LEARNING NOTE: The gate hands out "reply tickets" per player and for the whole
+	// server, and holds questions that arrive too fast so they get answered a bit later
+	gate := newReplyGate(cfg, time.Now())
+	queueTicker := time.NewTicker(time.Second)
+	defer queueTicker.Stop()
 
 	// 🎓 LEARNING NOTE: This is the main event loop! It runs forever, waiting for:
 	// 1. Ctrl+C (ctx.Done) - shutdown gracefully
-	// 2. Chat events (evt from chatCh) - process and maybe respond
+	// 2. Server events (evt from chatCh) - chat, joins, deaths, advancements...
+	// 3. The queue ticker - answer questions that had to wait their turn
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("Shutting down chatbot...")
 			return
 		case evt := <-chatCh:
-			log.Printf("[CHAT] <%s> %s", evt.Player, evt.Text)
-
-			// 🎓 LEARNING NOTE: Quick shortcut: if a camper yells for a rescue, we drop a golem immediately
-			if handledRescue, err := maybeHandleRescueGolem(ctx, cfg, evt); handledRescue {
-				if err != nil {
-					log.Printf("golem rescue error: %v", err)
-				} else {
-					lastReply = time.Now()
+			// Every line gets a transcript ID first, so replies and alerts can cite it
+			evt.Line = cfg.Transcript.Record(evt)
+			updateRoster(cfg, evt) // Joins, quits, and chatters keep the online list fresh
+			// 🎓 LEARNING NOTE: A "switch" on the event kind lets each kind of event
+			// get its own handler - like sorting mail into different mailboxes
+			switch evt.Kind {
+			case EventChat:
+				handleChatEvent(ctx, cfg, gate, pool, evt)
+			case EventJoin, EventDeath, EventAdvancement:
+				handleLifecycleEvent(ctx, cfg, gate, pool, evt)
+			case EventPlayerList:
+				// Already folded into the roster above
+			default:
+				log.Printf("[EVENT] %s: %s", evt.Kind, evt.Text)
+			}
+		case now := <-queueTicker.C:
+			expireTeleportRequests(ctx, cfg, pool, now)
+			for {
+				pending, ok := gate.Next(now)
+				if !ok {
+					break
 				}
-				continue
+				log.Printf("[QUEUE] Answering %s after %s", pending.Event.Player, now.Sub(pending.Queued).Round(time.Second))
+				submitAnswer(ctx, cfg, pool, pending)
 			}
+		}
+	}
+}
 
-			// 🎓 LEARNING NOTE: shouldRespond() uses heuristics to decide if Alfred should reply
-			// It checks: name mentions, trigger words (!bot), questions (?), alert keywords
-			replyPrompt, ok, alertTriggered := shouldRespond(cfg, evt)
-			if !ok {
-				continue // Not interesting, skip it
-			}
+// handleChatEvent runs one chat message through rescue shortcuts and trigger heuristics,
+// then either hands the answer to the worker pool or parks the question in the reply
+// queue when the player or the server as a whole is over its rate limit. It never
+// blocks on the LLM itself, so the main loop keeps draining chatCh.
+func handleChatEvent(ctx context.Context, cfg Config, gate *replyGate, pool *workerPool, evt ChatEvent) {
+	log.Printf("[CHAT] <%s> %s", evt.Player, evt.Text)
+	cfg.RecentChat.Add(evt)
 
-			// 🎓 LEARNING NOTE: Rate limiting prevents spam - Alfred won't reply too often
-			if time.Since(lastReply) < cfg.ReplyCooldown {
-				log.Printf("Skipping reply (cooldown). Message from %s", evt.Player)
-				continue
+	// 🎓 LEARNING NOTE: Quick shortcut: if a camper yells for a rescue, we drop a golem immediately
+	if isRescueCall(cfg, evt) {
+		pool.Submit(ctx, evt.Player, func(ctx context.Context) {
+			if _, err := maybeHandleRescueGolem(ctx, cfg, evt); err != nil {
+				log.Printf("golem rescue error: %v", err)
 			}
-			var moderationActions []ToolInvocation
-			if alertTriggered {
-				// 🎓 LEARNING NOTE: AI Safety in action! When toxic words are detected,
-				// we trigger a dramatic (but safe) lightning bolt as a warning
-				if err := triggerSafeLightning(ctx, cfg, evt.Player); err != nil {
-					log.Printf("lightning error: %v", err)
-				} else {
-					moderationActions = append(moderationActions, ToolInvocation{
-						Name:      "moderation_safe_lightning",
-						Arguments: fmt.Sprintf(`{"player":"%s"}`, evt.Player),
-						Output:    "Safe lightning triggered ahead of player.",
-					})
-				}
+		})
+		return
+	}
+
+	// Staff commands such as "!bot strikes Steve" are answered privately, never by the LLM
+	if name, args, ok := parseAdminCommand(cfg, evt); ok {
+		pool.Submit(ctx, evt.Player, func(ctx context.Context) {
+			runAdminCommand(ctx, cfg, evt, name, args)
+		})
+		return
+	}
+
+	// 🎓 LEARNING NOTE: The optional AI classifier is a slow network call, so it runs on
+	// the player's worker lane, and the answer then starts right there on the same lane
+	if cfg.Classifier != nil {
+		pool.Submit(ctx, evt.Player, func(ctx context.Context) {
+			pending, ok := reviewChat(ctx, cfg, evt)
+			if moderateChat(ctx, cfg, &pending) && ok {
+				admitReply(ctx, cfg, gate, pending, func(p pendingReply) { answerChat(ctx, cfg, p) })
 			}
-			log.Printf("[BOT] Triggered by %s. Prompt: %s", evt.Player, replyPrompt)
-
-			// 🎓 LEARNING NOTE: This is where the magic happens! callLLM sends the message
-			// to the AI (Demeterics/Groq), which decides how to respond and which tools to use
-			resp, toolLogs, err := callLLM(ctx, cfg, evt, replyPrompt)
-			if err != nil {
-				log.Printf("LLM error: %v", err)
-				continue
+		})
+		return
+	}
+
+	// 🎓 LEARNING NOTE: shouldRespond() uses heuristics to decide if Alfred should reply
+	// It checks: name mentions, trigger words (!bot), questions (?), alert keywords
+	replyPrompt, ok, verdict := shouldRespond(cfg, evt)
+	if !ok {
+		return // Not interesting, skip it
+	}
+	pending := pendingReply{Event: evt, Prompt: replyPrompt, Moderation: verdict, Queued: time.Now()}
+	if pending.Moderation != nil {
+		// 🎓 LEARNING NOTE: Safety first! Strikes and consequences never wait behind the
+		// rate limiter, so flooding chat can't dodge them - only Alfred's reply waits
+		pool.Submit(ctx, evt.Player, func(ctx context.Context) {
+			if moderateChat(ctx, cfg, &pending) {
+				admitReply(ctx, cfg, gate, pending, func(p pendingReply) { answerChat(ctx, cfg, p) })
 			}
-			log.Printf("[BOT] Response: %s", resp)
-			if err := sendToMinecraft(ctx, cfg, resp); err != nil {
+		})
+		return
+	}
+	admitReply(ctx, cfg, gate, pending, func(p pendingReply) { submitAnswer(ctx, cfg, pool, p) })
+}
+
+// moderateChat records the strike and carries out the moderation side effects for a
+// flagged message. It runs before any rate limit and logs the actions right away,
+// because a reply parked in the queue can still be dropped. A classifier verdict that
+// rejected a keyword hit is logged here too, even when nothing else answers the
+// message. Logged entries are cleared from pending so answerChat does not repeat them.
+// It reports whether a reply should follow.
+func moderateChat(ctx context.Context, cfg Config, pending *pendingReply) bool {
+	evt := pending.Event
+	var actions []ToolInvocation
+	if c := pending.Classification; c != nil && (pending.Moderation != nil || c.Outcome == "rejected") {
+		actions = append(actions, classifierInvocation(c))
+		pending.Classification = nil
+	}
+	wantsReply := true
+	if pending.Moderation != nil {
+		// 🎓 LEARNING NOTE: Each kind of problem gets its own response. Rude words earn a
+		// dramatic (but safe) lightning bolt; a camper who seems sad gets kindness instead.
+		// Repeat offenders collect strikes, and more strikes mean firmer consequences
+		verdict := recordStrike(cfg, evt.Player, *pending.Moderation, time.Now())
+		actions = append(actions, applyModerationActions(ctx, cfg, evt, verdict)...)
+		pending.Moderation = &verdict
+		wantsReply = verdict.WantsReply()
+	}
+	if len(actions) > 0 {
+		if err := logInteraction(cfg, evt, "", actions); err != nil {
+			log.Printf("log error: %v", err)
+		}
+	}
+	return wantsReply
+}
+
+// reviewChat is shouldRespond plus the classifier's second opinion: a rejected keyword
+// hit falls back to the ordinary triggers, and a flag on keyword-free chat becomes a
+// moderation reply.
+func reviewChat(ctx context.Context, cfg Config, evt ChatEvent) (pendingReply, bool) {
+	replyPrompt, ok, verdict := shouldRespond(cfg, evt)
+	reviewed, result := secondOpinion(ctx, cfg, evt, verdict)
+	switch {
+	case reviewed != nil:
+		replyPrompt, ok = reviewed.Prompt(evt.Text), true
+	case verdict != nil:
+		replyPrompt, ok = conversationPrompt(cfg, evt)
+	}
+	return pendingReply{Event: evt, Prompt: replyPrompt, Moderation: reviewed, Classification: result, Queued: time.Now()}, ok
+}
+
+// admitReply applies the rate limits to a reply Alfred has decided to give, passing it
+// to dispatch when allowed and parking it in the reply queue otherwise. Moderation has
+// already happened by now (see moderateChat); only the LLM reply is limited.
+func admitReply(ctx context.Context, cfg Config, gate *replyGate, pending pendingReply, dispatch func(pendingReply)) {
+	evt := pending.Event
+
+	// 🎓 LEARNING NOTE: Rate limiting prevents spam - each camper gets a few quick answers,
+	// then waits their turn, so one chatty player can't lock everyone else out
+	switch gate.Admit(evt.Player, pending.Queued) {
+	case admitAllowed:
+		dispatch(pending)
+	case admitPlayerLimited:
+		queued := gate.Enqueue(pending)
+		log.Printf("Player %s over quota; queued=%t (queue %d)", evt.Player, queued, gate.Len())
+		if cfg.RateLimitNotice != "" && gate.ShouldNotify(evt.Player, pending.Queued) {
+			if err := announce(ctx, cfg, fmt.Sprintf("%s, %s", evt.Player, cfg.RateLimitNotice)); err != nil {
 				log.Printf("send error: %v", err)
-				continue
-			}
-			if err := logInteraction(cfg.ResponseLog, evt, resp, append(moderationActions, toolLogs...)); err != nil {
-				log.Printf("log error: %v", err)
 			}
-			lastReply = time.Now()
 		}
+	case admitGlobalLimited:
+		queued := gate.Enqueue(pending)
+		log.Printf("Global reply limit reached; queued %s=%t (queue %d)", evt.Player, queued, gate.Len())
+	}
+}
+
+// submitAnswer queues answerChat on the worker lane owned by the asking player.
+func submitAnswer(ctx context.Context, cfg Config, pool *workerPool, pending pendingReply) {
+	pool.Submit(ctx, pending.Event.Player, func(ctx context.Context) {
+		answerChat(ctx, cfg, pending)
+	})
+}
+
+// answerChat asks the LLM, posts the reply, and logs the interaction. Both fresh and
+// queued questions end up here.
+func answerChat(ctx context.Context, cfg Config, pending pendingReply) {
+	evt, replyPrompt := pending.Event, pending.Prompt
+	var moderationActions []ToolInvocation
+	if pending.Classification != nil {
+		moderationActions = append(moderationActions, classifierInvocation(pending.Classification))
+	}
+	log.Printf("[BOT] Triggered by %s. Prompt: %s", evt.Player, replyPrompt)
+
+	// 🎓 LEARNING NOTE: This is where the magic happens! callLLM sends the message
+	// to the AI (Demeterics/Groq), which decides how to respond and which tools to use
+	resp, toolLogs, err := callLLM(ctx, cfg, evt, replyPrompt)
+	if err != nil {
+		log.Printf("LLM error: %v", err)
+		// 🎓 LEARNING NOTE: Never leave a camper hanging - a friendly fallback beats silence
+		sendLLMFailureNotice(ctx, cfg)
+		return
+	}
+	log.Printf("[BOT] Response: %s", resp)
+	if err := sendToMinecraft(ctx, cfg, resp); err != nil {
+		log.Printf("send error: %v", err)
+		return
+	}
+	if err := logInteraction(cfg, evt, resp, append(moderationActions, toolLogs...)); err != nil {
+		log.Printf("log error: %v", err)
+	}
+}
+
+// sendLLMFailureNotice posts the configured "ask again soon" line after every retry and
+// fallback model has failed.
+func sendLLMFailureNotice(ctx context.Context, cfg Config) {
+	if cfg.LLMFailureMessage == "" {
+		return
+	}
+	if err := announce(ctx, cfg, cfg.LLMFailureMessage); err != nil {
+		log.Printf("send error: %v", err)
 	}
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// drainLane waits until every job already queued on the player's lane has run.
+func drainLane(ctx context.Context, pool *workerPool, player string) {
+	done := make(chan struct{})
+	pool.Submit(ctx, player, func(context.Context) { close(done) })
+	<-done
+}
+
+func TestFlaggedChatStrikesEvenWhenRateLimited(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	strikes, err := newStrikeLedger("", 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	console := &fakeConsole{}
+	cfg := Config{
+		RobotName:          "Alfred",
+		EnableAlertTrigger: true,
+		Moderation:         testModeration(t),
+		Strikes:            strikes,
+		Console:            console,
+		LLM:                &scriptedProvider{},
+		PlayerBurst:        1,
+		ReplyCooldown:      time.Hour,
+		GlobalBurst:        10,
+		GlobalRefill:       time.Second,
+		ReplyQueueSize:     10,
+		ReplyQueueMaxAge:   time.Minute,
+	}
+	gate := newReplyGate(cfg, time.Now())
+	pool := newWorkerPool(ctx, 2, 8, &pipelineStats{})
+
+	for i := 0; i < 4; i++ {
+		handleChatEvent(ctx, cfg, gate, pool, ChatEvent{Kind: EventChat, Player: "Steve", Text: "you are trash", Time: time.Now()})
+	}
+	drainLane(ctx, pool, "Steve")
+
+	if got := len(strikes.Active("Steve", time.Now())); got != 4 {
+		t.Fatalf("active strikes = %d, want 4 (one per flagged message)", got)
+	}
+	var lightning, replies int
+	for _, command := range console.sent() {
+		switch {
+		case strings.Contains(command, "summon lightning_bolt"):
+			lightning++
+		case strings.HasPrefix(command, "say [Alfred]"):
+			replies++
+		}
+	}
+	if lightning != 4 {
+		t.Errorf("lightning bolts = %d, want 4", lightning)
+	}
+	if replies != 1 {
+		t.Errorf("LLM replies = %d, want 1 (the rest wait on the rate limit)", replies)
+	}
+}
+
+func TestCommandPrefixDoesNotHideAbuse(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	strikes, err := newStrikeLedger("", 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	consent, err := newTeleportConsent(time.Minute, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	console := &fakeConsole{}
+	cfg := Config{
+		RobotName:          "Alfred",
+		TriggerWord:        "!bot",
+		StaffNames:         []string{"Counselor"},
+		EnableAlertTrigger: true,
+		Moderation:         testModeration(t),
+		Strikes:            strikes,
+		Consent:            consent,
+		Console:            console,
+		LLM:                &scriptedProvider{},
+		PlayerBurst:        5,
+		ReplyCooldown:      time.Second,
+		GlobalBurst:        10,
+		GlobalRefill:       time.Second,
+	}
+	gate := newReplyGate(cfg, time.Now())
+	pool := newWorkerPool(ctx, 1, 8, &pipelineStats{})
+
+	for _, text := range []string{"!bot strikes you idiot", "!bot waypoint add loser", "!bot block idiot"} {
+		handleChatEvent(ctx, cfg, gate, pool, ChatEvent{Kind: EventChat, Player: "Steve", Text: text, Time: time.Now()})
+	}
+	drainLane(ctx, pool, "Steve")
+	handleChatEvent(ctx, cfg, gate, pool, ChatEvent{Kind: EventChat, Player: "Counselor", Text: "!bot strikes Steve", Time: time.Now()})
+	drainLane(ctx, pool, "Counselor")
+
+	if got := len(strikes.Active("Steve", time.Now())); got != 3 {
+		t.Fatalf("active strikes = %d, want 3 (commands from campers are moderated)", got)
+	}
+	var staffReply string
+	for _, command := range console.sent() {
+		if strings.HasPrefix(command, "tell Counselor ") {
+			staffReply = command
+		}
+	}
+	if !strings.Contains(staffReply, "Steve has 3 active strike(s)") {
+		t.Fatalf("staff reply = %q, want the strike summary", staffReply)
+	}
+}
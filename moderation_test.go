@@ -0,0 +1,96 @@
+package main
+
+import "testing"
+
+func TestModerationFalsePositives(t *testing.T) {
+	engine := testModeration(t)
+	clean := []string{
+		"hello everyone",
+		"I have class tomorrow",
+		"that creeper is trash",
+		"creepers are so annoying",
+		"this lag is garbage",
+		"stupid zombie broke my door",
+		"the skeletons were pathetic",
+		"we built a bomb shelter",
+		"throw it in the trash can",
+		"what grade are you in",
+		"how old are you",
+		"are you alone in that cave?",
+		"I'm going to kill this ender dragon",
+		"shell and hello and assassin",
+		"meet at 100 blocks north",
+		"snapshot 24w14a is out",
+		"the creeper will kill you",
+		"don't break your pickaxe",
+		"did the zombie hurt you?",
+		"I will kill the wither",
+		"alfred pull up the map",
+		"i'm useless at parkour",
+		"let's end it all with the dragon fight",
+		"snap me a screenshot",
+		"join our private chat for the build team",
+		"we built a bomb shelter",
+		"I crafted a crossbow, no gun needed",
+		"go away from the lava",
+		"don't get lost in the nether",
+		"lava can hurt you",
+	}
+	for _, text := range clean {
+		if verdict, ok := engine.Evaluate(text); ok {
+			t.Errorf("Evaluate(%q) flagged %s on %q", text, verdict.Category, verdict.Match)
+		}
+	}
+}
+
+func TestModerationHits(t *testing.T) {
+	engine := testModeration(t)
+	cases := []struct {
+		text     string
+		category moderationCategory
+	}{
+		{"you are trash", categoryInsult},
+		{"Steve is trash", categoryInsult},
+		{"you're a 1d1ot", categoryInsult},
+		{"stuuupid", categoryInsult},
+		{"k y s", categoryThreat},
+		{"k.y.s", categoryThreat},
+		{"that creeper is trash and you are trash", categoryInsult},
+		{"i want to die", categorySelfHarm},
+		{"what's your address?", categoryGrooming},
+		{"add me on snapchat", categoryGrooming},
+		{"don't tell your parents ok", categoryGrooming},
+		{"what the hell", categoryProfanity},
+		{"nobody likes you", categoryHarassment},
+		{"i will kill you", categoryThreat},
+		{"i'll break your face", categoryThreat},
+		{"the creeper won't save you, i'll hurt you", categoryThreat},
+		{"i'm bringing a gun tomorrow", categoryThreat},
+		{"i want to end it all", categorySelfHarm},
+		{"everyone is better off without me", categorySelfHarm},
+		{"what's your snap", categoryGrooming},
+		{"let's talk in private, just between us", categoryGrooming},
+	}
+	for _, tc := range cases {
+		verdict, ok := engine.Evaluate(tc.text)
+		if !ok || verdict.Category != tc.category {
+			t.Errorf("Evaluate(%q) = %s (%t), want %s", tc.text, verdict.Category, ok, tc.category)
+		}
+	}
+}
+
+func TestModerationCustomAllowWords(t *testing.T) {
+	engine, err := newModerationEngine([]string{"griefer"}, []string{"kill the wither", "{thing} griefer"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for text, want := range map[string]bool{
+		"let's kill the wither": false,
+		"zombie griefer again":  false,
+		"you griefer":           true,
+	} {
+		if _, ok := engine.Evaluate(text); ok != want {
+			t.Errorf("Evaluate(%q) flagged=%t, want %t", text, ok, want)
+		}
+	}
+}
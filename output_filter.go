@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// outputRejection explains why a reply was unfit to post. It is returned as an error so
+// callers can regenerate or fall back to the canned safe line.
+type outputRejection struct {
+	Check  string
+	Reason string
+}
+
+func (r *outputRejection) Error() string {
+	return fmt.Sprintf("reply rejected by %s check: %s", r.Check, r.Reason)
+}
+
+// The patterns are anchored tightly because game talk is full of numbers and dots:
+// coordinates ("-250 120 1500") are not phone numbers, "3 torches along the road" is not
+// an address, and "Great job.Me too" is not a link. Bare domains must be lowercase,
+// phone numbers need "-", "." or "(555)" separators, and street addresses need a
+// capitalized street name and suffix ("12 Oak Street").
+var (
+	urlPattern     = regexp.MustCompile(`(?i:\b(?:https?://|www\.)\S+)|\b[a-z0-9-]+(?:\.[a-z0-9-]+)*\.(?:com|net|org|io|gg|xyz|ru|co|me|ly|tv|app|dev)\b(?:/\S*)?`)
+	emailPattern   = regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}\b`)
+	phonePattern   = regexp.MustCompile(`(?:\+?\d{1,2}[\s.-])?(?:\(\d{3}\)\s?|\b\d{3}[.-])\d{3}[.-]\d{4}\b`)
+	ipPattern      = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	addressPattern = regexp.MustCompile(`\b\d{1,5}\s+(?:[A-Z][a-z]+\s+){1,3}(?:Street|St|Avenue|Ave|Road|Rd|Lane|Ln|Drive|Dr|Court|Ct|Boulevard|Blvd)\b`)
+	sentenceEnd    = regexp.MustCompile(`[.!?](?:\s|$)`)
+)
+
+// outputRejectCategories are moderation categories Alfred's own words must never fall
+// into; milder categories (profanity, insults) are scrubbed instead of rejected. Game
+// safety advice ("the creeper will kill you") passes because the moderation allowlist
+// covers it.
+var outputRejectCategories = map[moderationCategory]bool{
+	categorySexual:     true,
+	categoryThreat:     true,
+	categoryHarassment: true,
+}
+
+// outputFilter is the post-generation safety pipeline every generated line passes before
+// it is posted: links and profanity are scrubbed, over-long replies are trimmed at a
+// sentence boundary, and personal data or banned topics reject the reply outright.
+// Canned text the server owner configured skips it (see announce).
+//
+// 🎓 LEARNING NOTE: We filter what goes INTO the AI (moderation) and what comes OUT of it.
+// Even a well-prompted model occasionally says something it shouldn't, so a final
+// checkpoint catches it before kids ever see it.
+type outputFilter struct {
+	maxWords   int
+	moderation *moderationEngine
+	banned     []compiledPhrase
+}
+
+func newOutputFilter(maxWords int, moderation *moderationEngine, bannedTopics []string) *outputFilter {
+	return &outputFilter{maxWords: maxWords, moderation: moderation, banned: compilePhrases(bannedTopics)}
+}
+
+// Check runs the pipeline. It returns the cleaned text, or an *outputRejection when the
+// reply cannot be repaired. A nil filter passes text through untouched.
+func (f *outputFilter) Check(text string) (string, error) {
+	if f == nil {
+		return text, nil
+	}
+	if emailPattern.MatchString(text) || phonePattern.MatchString(text) || ipPattern.MatchString(text) || addressPattern.MatchString(text) {
+		return "", &outputRejection{Check: "pii", Reason: "contains an email, phone number, IP, or street address"}
+	}
+	text = strings.Join(strings.Fields(urlPattern.ReplaceAllString(text, "")), " ")
+
+	tokens := moderationTokens(text)
+	for _, topic := range f.banned {
+		if len(phraseMatches(tokens, topic.tokens)) > 0 {
+			return "", &outputRejection{Check: "topic", Reason: fmt.Sprintf("mentions banned topic %q", topic.source)}
+		}
+	}
+	verdict, flagged := f.moderation.Evaluate(text)
+	if flagged && outputRejectCategories[verdict.Category] {
+		return "", &outputRejection{Check: "safety", Reason: fmt.Sprintf("%s language (%q)", verdict.Category, verdict.Match)}
+	}
+	if flagged {
+		text = f.scrubWords(text)
+	}
+	text = truncateReply(text, f.maxWords)
+	if strings.TrimSpace(text) == "" {
+		return "", &outputRejection{Check: "empty", Reason: "nothing left after scrubbing"}
+	}
+	return text, nil
+}
+
+// scrubWords masks single words that trip the profanity or insult rules, keeping the
+// first letter so the sentence still reads naturally ("d***"). Check only calls it when
+// the whole line was flagged, so allowlisted game talk ("that creeper is trash") is left
+// alone.
+func (f *outputFilter) scrubWords(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		verdict, ok := f.moderation.Evaluate(word)
+		if !ok || (verdict.Category != categoryProfanity && verdict.Category != categoryInsult) {
+			continue
+		}
+		runes := []rune(word)
+		masked := []rune{runes[0]}
+		for _, r := range runes[1:] {
+			if strings.ContainsRune(".,!?;:", r) {
+				masked = append(masked, r)
+			} else {
+				masked = append(masked, '*')
+			}
+		}
+		words[i] = string(masked)
+	}
+	return strings.Join(words, " ")
+}
+
+// truncateReply enforces the word cap. It keeps as many whole sentences as fit and only
+// cuts mid-sentence (adding "...") when even the first sentence is too long.
+func truncateReply(text string, maxWords int) string {
+	if maxWords <= 0 || len(strings.Fields(text)) <= maxWords {
+		return text
+	}
+	kept := ""
+	rest := text
+	for {
+		loc := sentenceEnd.FindStringIndex(rest)
+		if loc == nil {
+			break
+		}
+		candidate := kept + rest[:loc[1]]
+		if len(strings.Fields(candidate)) > maxWords {
+			break
+		}
+		kept, rest = candidate, rest[loc[1]:]
+	}
+	if kept = strings.TrimSpace(kept); kept != "" {
+		return kept
+	}
+	words := strings.Fields(text)[:maxWords]
+	return strings.TrimRight(strings.Join(words, " "), ",;:-") + "..."
+}
+
+// finalizeReply filters an LLM reply before it is posted. On rejection it asks the model
+// once more (without tools, so nothing runs twice) and, if that also fails, falls back to
+// the canned safe line.
+func finalizeReply(ctx context.Context, cfg Config, prompt, reply string) (string, []ToolInvocation) {
+	if cfg.OutputFilter == nil {
+		return reply, nil
+	}
+	cleaned, err := cfg.OutputFilter.Check(reply)
+	if err == nil {
+		return cleaned, nil
+	}
+	log.Printf("[FILTER] %v; regenerating", err)
+	logs := []ToolInvocation{{Name: "output_filter", Arguments: `{"attempt":1}`, Output: reply, Error: err.Error()}}
+
+	retry, retryErr := regenerateReply(ctx, cfg, prompt, reply, err)
+	if retryErr == nil {
+		if cleaned, err = cfg.OutputFilter.Check(retry); err == nil {
+			return cleaned, logs
+		}
+		retryErr = err
+	}
+	log.Printf("[FILTER] regeneration failed (%v); using safe fallback", retryErr)
+	return cfg.SafeFallback, append(logs, ToolInvocation{Name: "output_filter", Arguments: `{"attempt":2}`, Output: retry, Error: retryErr.Error()})
+}
+
+// regenerateReply asks for a replacement reply, telling the model what was wrong.
+func regenerateReply(ctx context.Context, cfg Config, prompt, rejected string, reason error) (string, error) {
+	resp, err := doChatCompletion(ctx, cfg, ChatRequest{
+		Model: cfg.Model,
+		Messages: []Message{
+			{Role: "system", Content: cfg.SystemPrompt},
+			{Role: "user", Content: prompt},
+			{Role: "assistant", Content: rejected},
+			{Role: "user", Content: fmt.Sprintf("That reply can't be posted (%v). Answer again in under %d words: kid-safe, no links, no personal details.", reason, cfg.ReplyMaxWords)},
+		},
+		Temperature:         cfg.Temperature,
+		MaxCompletionTokens: cfg.MaxTokens,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("regeneration returned no choices")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
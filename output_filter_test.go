@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOutputFilterPassesGameTalk(t *testing.T) {
+	filter := newOutputFilter(0, testModeration(t), nil)
+	for _, text := range []string{
+		"Place 3 torches along the road so mobs stay away.",
+		"The stronghold is at -250 120 1500, bring ender pearls!",
+		"Head to 100 64 -200 and dig down two blocks.",
+		"Great job.Me too, I love that build!",
+		"Version 1.20.4 added armadillos.",
+		"That creeper is trash, build a wall!",
+		"Watch out, the creeper will kill you if it gets close.",
+		"Don't break your sword on obsidian, use a pickaxe.",
+		"Lava can hurt you, so carry a water bucket.",
+		"Kill the wither from a safe distance.",
+	} {
+		got, err := filter.Check(text)
+		if err != nil || got != text {
+			t.Errorf("Check(%q) = %q, %v; want it unchanged", text, got, err)
+		}
+	}
+}
+
+func TestOutputFilterRejectsPersonalData(t *testing.T) {
+	filter := newOutputFilter(0, testModeration(t), nil)
+	for _, text := range []string{
+		"Email me at steve@example.com",
+		"Call 555-123-4567 tonight",
+		"Call (555) 123-4567 tonight",
+		"Call +1 555.123.4567 tonight",
+		"Join 192.168.1.20 for the build",
+		"I live at 42 Oak Street",
+		"Meet me at 1200 North Maple Ave",
+	} {
+		var rejection *outputRejection
+		if _, err := filter.Check(text); !errors.As(err, &rejection) || rejection.Check != "pii" {
+			t.Errorf("Check(%q) = %v, want a pii rejection", text, err)
+		}
+	}
+}
+
+func TestOutputFilterStripsLinks(t *testing.T) {
+	filter := newOutputFilter(0, testModeration(t), nil)
+	cases := map[string]string{
+		"Check https://evil.example/skins now":  "Check now",
+		"Visit WWW.Example.com for maps":        "Visit for maps",
+		"The wiki at minecraft.net has recipes": "The wiki at has recipes",
+	}
+	for text, want := range cases {
+		if got, err := filter.Check(text); err != nil || got != want {
+			t.Errorf("Check(%q) = %q, %v; want %q", text, got, err, want)
+		}
+	}
+}
+
+func TestOutputFilterRejectsThreats(t *testing.T) {
+	filter := newOutputFilter(0, testModeration(t), nil)
+	for _, text := range []string{"I will kill you", "Nobody likes you, shut up"} {
+		var rejection *outputRejection
+		if _, err := filter.Check(text); !errors.As(err, &rejection) || rejection.Check != "safety" {
+			t.Errorf("Check(%q) = %v, want a safety rejection", text, err)
+		}
+	}
+}
+
+func TestOutputFilterMasksFlaggedWords(t *testing.T) {
+	filter := newOutputFilter(0, testModeration(t), nil)
+	got, err := filter.Check("what the hell, you are trash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "what the h***, you are t****" {
+		t.Fatalf("Check = %q, want the insult and profanity masked", got)
+	}
+}
+
+func TestAnnounceSkipsOutputFilter(t *testing.T) {
+	console := &fakeConsole{}
+	cfg := Config{
+		RobotName:    "Alfred",
+		Console:      console,
+		OutputFilter: newOutputFilter(5, testModeration(t), nil),
+		SafeFallback: "Let's keep it friendly!",
+	}
+	support := "You matter. Call or text 988-555-0100 any time, someone is there to listen."
+	if err := announce(context.Background(), cfg, support); err != nil {
+		t.Fatal(err)
+	}
+	if err := sendToMinecraft(context.Background(), cfg, "Call 555-123-4567 tonight"); err != nil {
+		t.Fatal(err)
+	}
+	commands := console.sent()
+	want := []string{"say [Alfred] " + support, "say [Alfred] Let's keep it friendly!"}
+	if strings.Join(commands, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("commands = %q, want %q", commands, want)
+	}
+}
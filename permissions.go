@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// staffGroup is the policy group MCCHATBOT_STAFF members always belong to.
+const staffGroup = "staff"
+
+// toolGroup is one policy group: who is in it, which tools they may use, and whether
+// cooldowns and quotas apply to them.
+type toolGroup struct {
+	Players   []string `json:"players"`
+	Tools     []string `json:"tools"`
+	Unlimited bool     `json:"unlimited"`
+}
+
+// toolLimit throttles one tool. Scope "player" (the default) counts each camper
+// separately; "server" shares the budget, which suits world-wide tools like set_time.
+type toolLimit struct {
+	Cooldown   string `json:"cooldown"`
+	DailyQuota int    `json:"daily_quota"`
+	Scope      string `json:"scope"`
+
+	cooldown time.Duration
+}
+
+// toolUsage tracks one counter: the last use and how many uses happened on Day.
+type toolUsage struct {
+	Last  time.Time
+	Day   string
+	Count int
+}
+
+// toolPolicy decides which tools each player may see and use. It is loaded from the
+// JSON file named by MCCHATBOT_TOOL_POLICY; without a file every tool stays open.
+//
+// 🎓 LEARNING NOTE: This is "role-based access control". Instead of listing every
+// camper for every tool, we put players into groups (staff, counselors, campers,
+// guests) and give each group a list of tools. Cooldowns and daily quotas stop one
+// camper from flipping the whole server to night ten times in a row.
+type toolPolicy struct {
+	Groups       map[string]toolGroup `json:"groups"`
+	DefaultGroup string               `json:"default_group"`
+	Limits       map[string]toolLimit `json:"limits"`
+
+	staff []string
+	mu    sync.Mutex
+	usage map[string]*toolUsage
+}
+
+// loadToolPolicy reads and validates the policy file. An empty path disables policies.
+func loadToolPolicy(path string, staff []string) (*toolPolicy, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p toolPolicy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	if p.DefaultGroup == "" {
+		p.DefaultGroup = "guests"
+	}
+	if _, ok := p.Groups[p.DefaultGroup]; !ok {
+		return nil, fmt.Errorf("default_group %q is not defined in groups", p.DefaultGroup)
+	}
+	for name, group := range p.Groups {
+		for _, player := range group.Players {
+			if _, err := validatePlayerName(player); err != nil {
+				return nil, fmt.Errorf("group %s: %w", name, err)
+			}
+		}
+	}
+	for tool, limit := range p.Limits {
+		if limit.Cooldown != "" {
+			if limit.cooldown, err = time.ParseDuration(limit.Cooldown); err != nil {
+				return nil, fmt.Errorf("limit for %s: invalid cooldown: %w", tool, err)
+			}
+		}
+		switch limit.Scope {
+		case "":
+			limit.Scope = "player"
+		case "player", "server":
+		default:
+			return nil, fmt.Errorf("limit for %s: scope must be player or server", tool)
+		}
+		p.Limits[tool] = limit
+	}
+	p.staff = staff
+	p.usage = make(map[string]*toolUsage)
+	return &p, nil
+}
+
+// groupsFor lists the groups the player belongs to, falling back to the default group.
+func (p *toolPolicy) groupsFor(player string) []toolGroup {
+	var groups []toolGroup
+	for name, group := range p.Groups {
+		if containsFold(group.Players, player) || (name == staffGroup && containsFold(p.staff, player)) {
+			groups = append(groups, group)
+		}
+	}
+	if len(groups) == 0 {
+		groups = append(groups, p.Groups[p.DefaultGroup])
+	}
+	return groups
+}
+
+// Allowed reports whether any of the player's groups grants the tool ("*" grants all).
+// A nil policy allows everything.
+func (p *toolPolicy) Allowed(player, tool string) bool {
+	if p == nil {
+		return true
+	}
+	for _, group := range p.groupsFor(player) {
+		for _, granted := range group.Tools {
+			if granted == "*" || strings.EqualFold(granted, tool) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *toolPolicy) unlimited(player string) bool {
+	for _, group := range p.groupsFor(player) {
+		if group.Unlimited {
+			return true
+		}
+	}
+	return false
+}
+
+// Use checks the tool's cooldown and daily quota for the player and, when there is room,
+// counts one use. The error explains the wait so the model can pass it on kindly.
+//
+// The use is charged up front, so two calls racing in one turn cannot both squeeze
+// under the quota. The returned refund takes it back; the caller runs it when the tool
+// then fails, so a command the server rejected costs the camper nothing.
+func (p *toolPolicy) Use(player, tool string, now time.Time) (refund func(), err error) {
+	refund = func() {}
+	if p == nil {
+		return refund, nil
+	}
+	limit, ok := p.Limits[tool]
+	if !ok || p.unlimited(player) {
+		return refund, nil
+	}
+	key := tool
+	if limit.Scope == "player" {
+		key = tool + "|" + strings.ToLower(player)
+	}
+	day := now.Format("2006-01-02")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	usage := p.usage[key]
+	if usage == nil {
+		usage = &toolUsage{}
+		p.usage[key] = usage
+	}
+	if usage.Day != day {
+		usage.Day, usage.Count = day, 0
+	}
+	if wait := usage.Last.Add(limit.cooldown).Sub(now); limit.cooldown > 0 && wait > 0 {
+		return refund, fmt.Errorf("%s is cooling down; it can be used again in %s", tool, wait.Round(time.Second))
+	}
+	if limit.DailyQuota > 0 && usage.Count >= limit.DailyQuota {
+		if limit.Scope == "server" {
+			return refund, fmt.Errorf("%s has been used %d times today, which is the camp's limit; try again tomorrow", tool, usage.Count)
+		}
+		return refund, fmt.Errorf("%s already used %s %d times today, which is the limit; try again tomorrow", player, tool, usage.Count)
+	}
+	previous := usage.Last
+	usage.Last = now
+	usage.Count++
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if usage.Day == day && usage.Count > 0 {
+			usage.Count--
+		}
+		if usage.Last.Equal(now) {
+			usage.Last = previous
+		}
+	}, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToolPolicyChargesOnlySuccessfulCalls(t *testing.T) {
+	policy := &toolPolicy{
+		Groups:       map[string]toolGroup{"guests": {Tools: []string{"*"}}},
+		DefaultGroup: "guests",
+		Limits:       map[string]toolLimit{fireworkToolName: {DailyQuota: 1, Scope: "player", cooldown: time.Minute}},
+		usage:        make(map[string]*toolUsage),
+	}
+	offline := true
+	console := &fakeConsole{reply: func(string) string {
+		if offline {
+			return "No player was found"
+		}
+		return ""
+	}}
+	cfg := Config{EnableToolUse: true, EnableEasterEggs: true, Console: console, ToolPolicy: policy}
+	registry, err := newToolRegistry(cfg, builtinTools()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offered := registry.Available(cfg, "Steve")
+	evt := ChatEvent{Player: "Steve"}
+	call := ToolCall{Function: ToolCallFunction{Name: fireworkToolName, Arguments: `{}`}}
+
+	if inv := registry.Run(context.Background(), cfg, evt, offered, call); inv.Error == "" {
+		t.Fatalf("expected the offline call to fail, got %+v", inv)
+	}
+	offline = false
+	if inv := registry.Run(context.Background(), cfg, evt, offered, call); inv.Error != "" {
+		t.Fatalf("failed call used up the quota or cooldown: %s", inv.Error)
+	}
+	inv := registry.Run(context.Background(), cfg, evt, offered, call)
+	if !strings.Contains(inv.Error, "cooling down") {
+		t.Fatalf("third call error = %q, want the cooldown from the successful call", inv.Error)
+	}
+}
+
+func TestToolPolicyRefundKeepsLaterUse(t *testing.T) {
+	policy := &toolPolicy{
+		Limits: map[string]toolLimit{"set_time": {DailyQuota: 2, Scope: "server"}},
+		usage:  make(map[string]*toolUsage),
+	}
+	now := time.Now()
+	first, err := policy.Use("Steve", "set_time", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := policy.Use("Alex", "set_time", now.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	first()
+	usage := policy.usage["set_time"]
+	if usage.Count != 1 || !usage.Last.Equal(now.Add(time.Second)) {
+		t.Fatalf("usage after refund = %+v, want Alex's use kept", usage)
+	}
+}
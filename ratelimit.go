@@ -0,0 +1,237 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a classic token bucket: it holds up to burst tokens and regains one
+// every refill interval. Each reply spends one token.
+//
+// 🎓 LEARNING NOTE: Imagine a jar of tickets. Every answer costs a ticket, and a new
+// ticket drops in every few seconds. Empty jar = wait your turn!
+type tokenBucket struct {
+	burst  float64
+	refill time.Duration
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(burst int, refill time.Duration, now time.Time) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{burst: float64(burst), refill: refill, tokens: float64(burst), last: now}
+}
+
+// advance credits the tokens earned since the last observation.
+func (b *tokenBucket) advance(now time.Time) {
+	if b.refill <= 0 {
+		b.tokens = b.burst
+		b.last = now
+		return
+	}
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens += float64(elapsed) / float64(b.refill)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+func (b *tokenBucket) ready(now time.Time) bool {
+	b.advance(now)
+	return b.tokens >= 1
+}
+
+func (b *tokenBucket) take() {
+	b.tokens--
+}
+
+// admitResult explains why a reply may or may not go out right now.
+type admitResult int
+
+const (
+	admitAllowed admitResult = iota
+	admitPlayerLimited
+	admitGlobalLimited
+)
+
+// pendingReply is a chat question that passed shouldRespond but has not been answered.
+type pendingReply struct {
+	Event          ChatEvent
+	Prompt         string
+	Moderation     *moderationVerdict
+	Classification *classification
+	Queued         time.Time
+}
+
+// replyGate combines per-player and global token buckets with a bounded queue, so one
+// chatty camper cannot starve everyone else and deferred questions still get answered.
+// All methods are safe for concurrent use.
+type replyGate struct {
+	mu           sync.Mutex
+	global       *tokenBucket
+	players      map[string]*tokenBucket
+	playerBurst  int
+	playerRefill time.Duration
+	queue        []pendingReply
+	queueSize    int
+	perPlayerCap int
+	maxAge       time.Duration
+	noticed      map[string]time.Time
+}
+
+// newReplyGate builds the limiter from config. Every player may hold at least one
+// queue slot.
+func newReplyGate(cfg Config, now time.Time) *replyGate {
+	perPlayerCap := cfg.ReplyQueuePerPlayer
+	if perPlayerCap < 1 {
+		perPlayerCap = 1
+	}
+	return &replyGate{
+		global:       newTokenBucket(cfg.GlobalBurst, cfg.GlobalRefill, now),
+		players:      make(map[string]*tokenBucket),
+		playerBurst:  cfg.PlayerBurst,
+		playerRefill: cfg.ReplyCooldown,
+		queueSize:    cfg.ReplyQueueSize,
+		perPlayerCap: perPlayerCap,
+		maxAge:       cfg.ReplyQueueMaxAge,
+		noticed:      make(map[string]time.Time),
+	}
+}
+
+func (g *replyGate) playerBucket(player string, now time.Time) *tokenBucket {
+	key := strings.ToLower(player)
+	bucket, ok := g.players[key]
+	if !ok {
+		bucket = newTokenBucket(g.playerBurst, g.playerRefill, now)
+		g.players[key] = bucket
+	}
+	return bucket
+}
+
+// Admit spends a token from both the player's and the global bucket when both have
+// one. Nothing is spent on refusal, so a limited player never drains the global pool.
+func (g *replyGate) Admit(player string, now time.Time) admitResult {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.admitLocked(player, now)
+}
+
+func (g *replyGate) admitLocked(player string, now time.Time) admitResult {
+	bucket := g.playerBucket(player, now)
+	if !bucket.ready(now) {
+		return admitPlayerLimited
+	}
+	if !g.global.ready(now) {
+		return admitGlobalLimited
+	}
+	bucket.take()
+	g.global.take()
+	return admitAllowed
+}
+
+// AdmitGlobal spends only a global token; server events such as joins use it because
+// the player they mention did not ask for anything.
+func (g *replyGate) AdmitGlobal(now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.global.ready(now) {
+		return false
+	}
+	g.global.take()
+	return true
+}
+
+// Enqueue defers a question. Repeats of a question already waiting are coalesced, and
+// a player may only hold perPlayerCap slots (their oldest is replaced). It returns false
+// when the question was coalesced or the queue is full.
+func (g *replyGate) Enqueue(p pendingReply) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.queueSize <= 0 {
+		return false
+	}
+	key := coalesceKey(p.Event.Player, p.Prompt)
+	oldest, count := -1, 0
+	for i, queued := range g.queue {
+		if !strings.EqualFold(queued.Event.Player, p.Event.Player) {
+			continue
+		}
+		if coalesceKey(queued.Event.Player, queued.Prompt) == key {
+			// An alert must not be lost because a harmless twin was queued first.
+			if p.Moderation != nil && (queued.Moderation == nil || p.Moderation.Severity > queued.Moderation.Severity) {
+				g.queue[i].Moderation = p.Moderation
+			}
+			return false
+		}
+		if oldest == -1 {
+			oldest = i
+		}
+		count++
+	}
+	if count >= g.perPlayerCap && oldest >= 0 {
+		g.queue = append(g.queue[:oldest], g.queue[oldest+1:]...)
+	}
+	if len(g.queue) >= g.queueSize {
+		return false
+	}
+	g.queue = append(g.queue, p)
+	return true
+}
+
+// Next pops the oldest queued question whose player and the global pool both have a
+// token, spending them. Questions older than maxAge are discarded as stale.
+func (g *replyGate) Next(now time.Time) (pendingReply, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	kept := g.queue[:0]
+	for _, p := range g.queue {
+		if g.maxAge <= 0 || now.Sub(p.Queued) <= g.maxAge {
+			kept = append(kept, p)
+		}
+	}
+	g.queue = kept
+	for i, p := range g.queue {
+		switch g.admitLocked(p.Event.Player, now) {
+		case admitAllowed:
+			g.queue = append(g.queue[:i], g.queue[i+1:]...)
+			return p, true
+		case admitGlobalLimited:
+			// Nobody else can go either until the global bucket refills.
+			return pendingReply{}, false
+		}
+	}
+	return pendingReply{}, false
+}
+
+// Len reports how many questions are waiting.
+func (g *replyGate) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.queue)
+}
+
+// ShouldNotify reports whether the player should hear the "one at a time" notice, at
+// most once per refill interval so the notice itself never becomes spam.
+func (g *replyGate) ShouldNotify(player string, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	key := strings.ToLower(player)
+	if last, ok := g.noticed[key]; ok && now.Sub(last) < g.playerRefill {
+		return false
+	}
+	g.noticed[key] = now
+	return true
+}
+
+// coalesceKey normalizes case and whitespace so "How do I fly?" and "how do i  fly?"
+// count as the same question.
+func coalesceKey(player, prompt string) string {
+	return strings.ToLower(player) + "\x00" + strings.Join(strings.Fields(strings.ToLower(prompt)), " ")
+}
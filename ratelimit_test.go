@@ -0,0 +1,151 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketRefills(t *testing.T) {
+	start := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	bucket := newTokenBucket(2, 10*time.Second, start)
+
+	for i := 0; i < 2; i++ {
+		if !bucket.ready(start) {
+			t.Fatalf("token %d of the burst missing", i+1)
+		}
+		bucket.take()
+	}
+	if bucket.ready(start) {
+		t.Fatal("empty bucket still ready")
+	}
+	if bucket.ready(start.Add(5 * time.Second)) {
+		t.Fatal("half a refill interval produced a whole token")
+	}
+	if !bucket.ready(start.Add(10 * time.Second)) {
+		t.Fatal("no token after a full refill interval")
+	}
+	bucket.take()
+
+	// A long quiet spell refills only up to the burst.
+	later := start.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if !bucket.ready(later) {
+			t.Fatalf("token %d missing after an idle hour", i+1)
+		}
+		bucket.take()
+	}
+	if bucket.ready(later) {
+		t.Fatal("bucket held more than its burst")
+	}
+}
+
+func TestReplyGateAdmit(t *testing.T) {
+	start := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	gate := newReplyGate(Config{PlayerBurst: 1, ReplyCooldown: 30 * time.Second, GlobalBurst: 2, GlobalRefill: 10 * time.Second}, start)
+
+	steps := []struct {
+		player string
+		after  time.Duration
+		want   admitResult
+	}{
+		{"Steve", 0, admitAllowed},
+		{"steve", 0, admitPlayerLimited}, // names are case-insensitive
+		{"Alex", 0, admitAllowed},        // Steve's refusal spent no global token
+		{"Notch", 0, admitGlobalLimited},
+		{"Notch", 10 * time.Second, admitAllowed},
+		{"Steve", 20 * time.Second, admitPlayerLimited},
+		{"Steve", 30 * time.Second, admitAllowed},
+	}
+	for i, step := range steps {
+		if got := gate.Admit(step.player, start.Add(step.after)); got != step.want {
+			t.Fatalf("step %d: Admit(%s, +%s) = %d, want %d", i, step.player, step.after, got, step.want)
+		}
+	}
+}
+
+func TestReplyGateQueueLimits(t *testing.T) {
+	now := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	question := func(player, prompt string) pendingReply {
+		return pendingReply{Event: ChatEvent{Player: player, Text: prompt}, Prompt: prompt, Queued: now}
+	}
+	gate := newReplyGate(Config{PlayerBurst: 1, ReplyCooldown: time.Minute, GlobalBurst: 10, ReplyQueueSize: 3, ReplyQueuePerPlayer: 2, ReplyQueueMaxAge: 2 * time.Minute}, now)
+
+	for _, step := range []struct {
+		reply pendingReply
+		want  bool
+	}{
+		{question("Steve", "how do I fly?"), true},
+		{question("Steve", "where is spawn?"), true},
+		{question("steve", "How do I  fly?"), false}, // coalesced with the waiting twin
+		{question("Steve", "can I have diamonds?"), true},
+		{question("Alex", "what is redstone?"), true},
+		{question("Notch", "hello?"), false}, // queue full
+	} {
+		if got := gate.Enqueue(step.reply); got != step.want {
+			t.Fatalf("Enqueue(%s: %q) = %v, want %v", step.reply.Event.Player, step.reply.Prompt, got, step.want)
+		}
+	}
+	if gate.Len() != 3 {
+		t.Fatalf("queue holds %d, want 3", gate.Len())
+	}
+
+	var order []string
+	for {
+		next, ok := gate.Next(now)
+		if !ok {
+			break
+		}
+		order = append(order, next.Prompt)
+	}
+	// Steve's oldest question made room for his newest; his second one waits for a token.
+	want := []string{"where is spawn?", "what is redstone?"}
+	if len(order) != len(want) || order[0] != want[0] || order[1] != want[1] {
+		t.Fatalf("answered %q, want %q", order, want)
+	}
+	if gate.Len() != 1 {
+		t.Fatalf("queue holds %d after draining, want Steve's last question", gate.Len())
+	}
+	if _, ok := gate.Next(now.Add(5 * time.Minute)); ok || gate.Len() != 0 {
+		t.Fatalf("stale question was answered or kept (len %d)", gate.Len())
+	}
+}
+
+func TestReplyGatePerPlayerCapFromConfig(t *testing.T) {
+	now := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		configured, want int
+	}{
+		{configured: 0, want: 1},
+		{configured: 1, want: 1},
+		{configured: 3, want: 3},
+	} {
+		gate := newReplyGate(Config{ReplyQueueSize: 10, ReplyQueuePerPlayer: tc.configured}, now)
+		for _, prompt := range []string{"one", "two", "three", "four"} {
+			gate.Enqueue(pendingReply{Event: ChatEvent{Player: "Steve"}, Prompt: prompt, Queued: now})
+		}
+		if gate.Len() != tc.want {
+			t.Errorf("per-player cap %d: Steve holds %d slots, want %d", tc.configured, gate.Len(), tc.want)
+		}
+	}
+}
+
+func TestReplyGateKeepsAlertOnCoalescedTwin(t *testing.T) {
+	now := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	gate := newReplyGate(Config{ReplyQueueSize: 5, ReplyQueuePerPlayer: 2, GlobalBurst: 1, PlayerBurst: 1}, now)
+	gate.Enqueue(pendingReply{Event: ChatEvent{Player: "Alex"}, Prompt: "nobody likes me", Queued: now})
+	alert := &moderationVerdict{Severity: 3}
+	if gate.Enqueue(pendingReply{Event: ChatEvent{Player: "Alex"}, Prompt: "Nobody likes me", Moderation: alert, Queued: now}) {
+		t.Fatal("duplicate question was queued twice")
+	}
+	next, ok := gate.Next(now)
+	if !ok || next.Moderation != alert {
+		t.Fatalf("queued question lost the alert from its twin: %+v", next)
+	}
+}
+
+func TestReplyGateDisabledQueue(t *testing.T) {
+	gate := newReplyGate(Config{ReplyQueueSize: 0}, time.Now())
+	if gate.Enqueue(pendingReply{Event: ChatEvent{Player: "Steve"}, Prompt: "hi"}) || gate.Len() != 0 {
+		t.Fatal("a zero-size queue accepted a question")
+	}
+}
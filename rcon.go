@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RCON packet types as defined by the Source RCON protocol that Minecraft implements.
+const (
+	rconTypeResponse = 0
+	rconTypeCommand  = 2
+	rconTypeAuth     = 3
+
+	// rconMaxCommand is the largest command body the vanilla server accepts in one packet.
+	rconMaxCommand = 1446
+	// rconMaxPacket bounds incoming packets so a misbehaving server cannot exhaust memory.
+	rconMaxPacket = 4096 + 14
+)
+
+var errRconAuth = errors.New("rcon authentication failed")
+
+// rconPacket is one framed RCON message: request ID, packet type, and ASCII body.
+type rconPacket struct {
+	ID   int32
+	Type int32
+	Body string
+}
+
+// rconTransport speaks the RCON protocol so console commands return the server's reply.
+// The connection is dialed lazily, authenticated once, and re-dialed after any I/O error.
+type rconTransport struct {
+	addr     string
+	password string
+	timeout  time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	nextID int32
+}
+
+// newRconTransport prepares an RCON client without dialing; the first command connects.
+func newRconTransport(addr, password string, timeout time.Duration) *rconTransport {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &rconTransport{addr: addr, password: password, timeout: timeout}
+}
+
+// Run sends a single command and collects every response fragment the server returns.
+// Minecraft splits long replies into several packets sharing one ID, so a follow-up
+// sentinel packet is sent and reading stops once the server echoes that sentinel back.
+func (t *rconTransport) Run(ctx context.Context, command string) (string, error) {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return "", errors.New("empty console command")
+	}
+	if len(command) > rconMaxCommand {
+		return "", fmt.Errorf("console command too long for rcon (%d bytes)", len(command))
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.ensureConnected(ctx); err != nil {
+		return "", err
+	}
+	reply, err := t.exchange(ctx, command)
+	if err != nil {
+		// Drop the connection so the next command starts from a clean handshake.
+		t.closeLocked()
+		return "", err
+	}
+	return reply, nil
+}
+
+// Close tears down the RCON connection if one is open.
+func (t *rconTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closeLocked()
+}
+
+func (t *rconTransport) closeLocked() error {
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	t.reader = nil
+	return err
+}
+
+// ensureConnected dials and authenticates when no live connection exists.
+func (t *rconTransport) ensureConnected(ctx context.Context) error {
+	if t.conn != nil {
+		return nil
+	}
+	dialer := net.Dialer{Timeout: t.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return fmt.Errorf("rcon dial %s: %w", t.addr, err)
+	}
+	t.conn = conn
+	t.reader = bufio.NewReader(conn)
+
+	if err := t.setDeadline(ctx); err != nil {
+		t.closeLocked()
+		return err
+	}
+	authID := t.allocID()
+	if err := t.write(rconPacket{ID: authID, Type: rconTypeAuth, Body: t.password}); err != nil {
+		t.closeLocked()
+		return err
+	}
+	for {
+		pkt, err := t.read()
+		if err != nil {
+			t.closeLocked()
+			return err
+		}
+		// Some servers emit an empty response value ahead of the auth result; skip it.
+		if pkt.Type == rconTypeResponse {
+			continue
+		}
+		if pkt.ID == -1 || pkt.ID != authID {
+			t.closeLocked()
+			return errRconAuth
+		}
+		return nil
+	}
+}
+
+// exchange writes the command plus a sentinel packet and stitches fragments together.
+func (t *rconTransport) exchange(ctx context.Context, command string) (string, error) {
+	if err := t.setDeadline(ctx); err != nil {
+		return "", err
+	}
+	cmdID := t.allocID()
+	sentinelID := t.allocID()
+	if err := t.write(rconPacket{ID: cmdID, Type: rconTypeCommand, Body: command}); err != nil {
+		return "", err
+	}
+	if err := t.write(rconPacket{ID: sentinelID, Type: rconTypeResponse}); err != nil {
+		return "", err
+	}
+	var reply strings.Builder
+	for {
+		pkt, err := t.read()
+		if err != nil {
+			return "", err
+		}
+		switch pkt.ID {
+		case cmdID:
+			reply.WriteString(pkt.Body)
+		case sentinelID:
+			return strings.TrimSpace(reply.String()), nil
+		case -1:
+			return "", errRconAuth
+		}
+	}
+}
+
+func (t *rconTransport) allocID() int32 {
+	t.nextID++
+	if t.nextID <= 0 {
+		t.nextID = 1
+	}
+	return t.nextID
+}
+
+func (t *rconTransport) setDeadline(ctx context.Context) error {
+	deadline := time.Now().Add(t.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	return t.conn.SetDeadline(deadline)
+}
+
+func (t *rconTransport) write(pkt rconPacket) error {
+	_, err := t.conn.Write(encodeRconPacket(pkt))
+	return err
+}
+
+func (t *rconTransport) read() (rconPacket, error) {
+	return decodeRconPacket(t.reader)
+}
+
+// encodeRconPacket frames a packet: little-endian length, ID, type, body, two NUL bytes.
+func encodeRconPacket(pkt rconPacket) []byte {
+	size := 4 + 4 + len(pkt.Body) + 2
+	buf := make([]byte, 4+size)
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(size))
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(pkt.ID))
+	binary.LittleEndian.PutUint32(buf[8:12], uint32(pkt.Type))
+	copy(buf[12:], pkt.Body)
+	return buf
+}
+
+// decodeRconPacket reads one framed packet and validates its declared length.
+func decodeRconPacket(r io.Reader) (rconPacket, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return rconPacket{}, err
+	}
+	size := int32(binary.LittleEndian.Uint32(header[:]))
+	if size < 10 || size > rconMaxPacket {
+		return rconPacket{}, fmt.Errorf("rcon packet size %d out of range", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return rconPacket{}, err
+	}
+	body := payload[8 : size-2]
+	return rconPacket{
+		ID:   int32(binary.LittleEndian.Uint32(payload[0:4])),
+		Type: int32(binary.LittleEndian.Uint32(payload[4:8])),
+		Body: string(body),
+	}, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRconServer is a local stand-in for the Minecraft RCON listener. handle answers each
+// command packet by returning the reply fragments to send back under the request's ID.
+type fakeRconServer struct {
+	password string
+	handle   func(command string) []string
+	// stray, when set, is sent under an unrelated ID before each reply so the client
+	// has to match responses by request ID rather than by arrival order.
+	stray bool
+
+	listener net.Listener
+	commands chan string
+}
+
+func startFakeRconServer(t *testing.T, srv *fakeRconServer) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv.listener = ln
+	srv.commands = make(chan string, 16)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (s *fakeRconServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	send := func(id, typ int32, body string) {
+		conn.Write(encodeRconPacket(rconPacket{ID: id, Type: typ, Body: body}))
+	}
+	for {
+		pkt, err := decodeRconPacket(reader)
+		if err != nil {
+			return
+		}
+		switch pkt.Type {
+		case rconTypeAuth:
+			// Vanilla sends an empty response value ahead of the auth result.
+			send(pkt.ID, rconTypeResponse, "")
+			if pkt.Body != s.password {
+				send(-1, rconTypeCommand, "")
+				return
+			}
+			send(pkt.ID, rconTypeCommand, "")
+		case rconTypeCommand:
+			s.commands <- pkt.Body
+			if s.stray {
+				send(pkt.ID+1000, rconTypeResponse, "There are 0 of a max of 20 players online:")
+			}
+			for _, fragment := range s.handle(pkt.Body) {
+				send(pkt.ID, rconTypeResponse, fragment)
+			}
+		case rconTypeResponse:
+			// The client's sentinel: vanilla answers unknown packet types with the same ID.
+			send(pkt.ID, rconTypeResponse, "Unknown request 0")
+		}
+	}
+}
+
+func TestRconTransportAuthAndReply(t *testing.T) {
+	srv := &fakeRconServer{password: "hunter2", handle: func(command string) []string {
+		return []string{"Teleported Steve to Alex"}
+	}}
+	rcon := newRconTransport(startFakeRconServer(t, srv), "hunter2", time.Second)
+	defer rcon.Close()
+
+	for i := 0; i < 2; i++ {
+		reply, err := rcon.Run(context.Background(), "tp Steve Alex")
+		if err != nil {
+			t.Fatalf("run %d: %v", i, err)
+		}
+		if reply != "Teleported Steve to Alex" {
+			t.Fatalf("run %d: reply = %q", i, reply)
+		}
+		if got := <-srv.commands; got != "tp Steve Alex" {
+			t.Fatalf("server received %q", got)
+		}
+	}
+}
+
+func TestRconTransportWrongPassword(t *testing.T) {
+	srv := &fakeRconServer{password: "hunter2", handle: func(string) []string { return nil }}
+	rcon := newRconTransport(startFakeRconServer(t, srv), "guess", time.Second)
+	defer rcon.Close()
+
+	if _, err := rcon.Run(context.Background(), "list"); !errors.Is(err, errRconAuth) {
+		t.Fatalf("err = %v, want errRconAuth", err)
+	}
+}
+
+func TestRconTransportMatchesRequestIDs(t *testing.T) {
+	srv := &fakeRconServer{password: "pw", stray: true, handle: func(command string) []string {
+		return []string{"reply to " + command}
+	}}
+	rcon := newRconTransport(startFakeRconServer(t, srv), "pw", time.Second)
+	defer rcon.Close()
+
+	for _, command := range []string{"list", "time set day", "weather clear"} {
+		reply, err := rcon.Run(context.Background(), command)
+		if err != nil {
+			t.Fatalf("%s: %v", command, err)
+		}
+		if reply != "reply to "+command {
+			t.Fatalf("%s: reply = %q", command, reply)
+		}
+	}
+}
+
+func TestRconTransportJoinsFragments(t *testing.T) {
+	long := strings.Repeat("a", 4000) + strings.Repeat("b", 4000) + "c"
+	srv := &fakeRconServer{password: "pw", handle: func(string) []string {
+		return []string{long[:4000], long[4000:8000], long[8000:]}
+	}}
+	rcon := newRconTransport(startFakeRconServer(t, srv), "pw", time.Second)
+	defer rcon.Close()
+
+	reply, err := rcon.Run(context.Background(), "help")
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if reply != long {
+		t.Fatalf("reply has %d bytes, want %d joined from three fragments", len(reply), len(long))
+	}
+}
+
+func TestConsoleReplyError(t *testing.T) {
+	cases := []struct {
+		reply string
+		fail  bool
+	}{
+		{"Teleported Steve to Alex", false},
+		{"Teleported InvalidGamer to Steve", false},
+		{"There are 2 of a max of 20 players online: InvalidGamer, Steve", false},
+		{"No player was found", true},
+		{"Unknown or incomplete command, see below for error", true},
+		{"Invalid name or UUID", true},
+		{"That position is not loaded", true},
+		{"", false},
+	}
+	for _, tc := range cases {
+		if err := consoleReplyError(tc.reply); (err != nil) != tc.fail {
+			t.Errorf("consoleReplyError(%q) = %v, want failure %v", tc.reply, err, tc.fail)
+		}
+	}
+}
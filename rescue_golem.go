@@ -11,21 +11,17 @@ import (
 // drops an iron golem beside the camper plus a quick reassurance message. It
 // returns whether the trigger fired so the caller can skip normal LLM routing.
 func maybeHandleRescueGolem(ctx context.Context, cfg Config, evt ChatEvent) (bool, error) {
-	if !cfg.EnableEasterEggs {
-		return false, nil
-	}
-	lower := strings.ToLower(evt.Text)
-	if !(strings.Contains(lower, "alfred to the rescue") || strings.Contains(lower, "alfred, help me") || strings.Contains(lower, "alfred help me")) {
+	if !isRescueCall(cfg, evt) {
 		return false, nil
 	}
 	if err := summonGolemGuard(ctx, cfg, evt.Player); err != nil {
 		return true, err
 	}
 	response := "Golem guard incoming - stay behind the big buddy!"
-	if err := sendToMinecraft(ctx, cfg, response); err != nil {
+	if err := announce(ctx, cfg, response); err != nil {
 		return true, err
 	}
-	if err := logInteraction(cfg.ResponseLog, evt, response, []ToolInvocation{{
+	if err := logInteraction(cfg, evt, response, []ToolInvocation{{
 		Name:      golemGuardToolName,
 		Arguments: fmt.Sprintf(`{"player":"%s"}`, strings.TrimSpace(evt.Player)),
 		Output:    "Iron golem summoned beside player.",
@@ -34,3 +30,13 @@ func maybeHandleRescueGolem(ctx context.Context, cfg Config, evt ChatEvent) (boo
 	}
 	return true, nil
 }
+
+// isRescueCall reports whether the chat line is one of the rescue phrases. It is cheap
+// and side-effect free, so the main loop can check it before handing work to a worker.
+func isRescueCall(cfg Config, evt ChatEvent) bool {
+	if !cfg.EnableEasterEggs {
+		return false
+	}
+	lower := strings.ToLower(evt.Text)
+	return strings.Contains(lower, "alfred to the rescue") || strings.Contains(lower, "alfred, help me") || strings.Contains(lower, "alfred help me")
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// listReplyPattern matches the vanilla `list` output, both the modern
+// "There are 2 of a max of 20 players online: Steve, Alex" and the older "2/20" layout.
+var listReplyPattern = regexp.MustCompile(`^There are \d+ ?(?:of a max of \d+|/ ?\d+) players online:\s*(.*)$`)
+
+// playerRoster tracks who is online, fed by join/leave lines and reconciled with the
+// `list` command. Tool executors use it to refuse targets who are not on the server.
+//
+// 🎓 LEARNING NOTE: Without a roster, "tp me to stve" would quietly fail and Alfred would
+// still say "Done!". Knowing who is online lets Alfred say "Nobody named stve is here"
+// or fix the typo for you.
+type playerRoster struct {
+	mu      sync.Mutex
+	players map[string]string // lowercase key -> name as the server spells it
+	synced  bool              // true once a full list (or a server start/stop) was seen
+}
+
+func newPlayerRoster() *playerRoster {
+	return &playerRoster{players: make(map[string]string)}
+}
+
+// Join marks a player as online. Chatting players are joined too, since they are
+// obviously on the server even if the bot missed their join line.
+func (r *playerRoster) Join(player string) {
+	if r == nil || !isPlausiblePlayerName(player) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.players[strings.ToLower(player)] = player
+}
+
+// Leave marks a player as offline.
+func (r *playerRoster) Leave(player string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.players, strings.ToLower(player))
+}
+
+// Replace swaps in an authoritative player list, e.g. from `list` or a server restart.
+func (r *playerRoster) Replace(players []string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.players = make(map[string]string, len(players))
+	for _, p := range players {
+		if isPlausiblePlayerName(p) {
+			r.players[strings.ToLower(p)] = p
+		}
+	}
+	r.synced = true
+}
+
+// Resolve maps a name the model or a camper typed onto an online player: an exact
+// match first, then a unique prefix ("steve" -> "Steve_2011"), a unique substring, and
+// finally a single close spelling. Until the roster has seen a full list it cannot
+// prove someone is offline, so unknown but valid names are passed through.
+func (r *playerRoster) Resolve(name string) (string, error) {
+	name, err := validatePlayerName(name)
+	if err != nil || r == nil {
+		return name, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lower := strings.ToLower(name)
+	typos := 1
+	if len(lower) >= 6 {
+		typos = 2
+	}
+	if exact, ok := r.players[lower]; ok {
+		return exact, nil
+	}
+	for _, match := range []func(string) bool{
+		func(key string) bool { return strings.HasPrefix(key, lower) },
+		func(key string) bool { return strings.Contains(key, lower) },
+		func(key string) bool { return editDistance(key, lower) <= typos },
+	} {
+		var found []string
+		for key, display := range r.players {
+			if match(key) {
+				found = append(found, display)
+			}
+		}
+		switch len(found) {
+		case 0:
+			continue
+		case 1:
+			return found[0], nil
+		default:
+			sort.Strings(found)
+			return "", fmt.Errorf("%q could mean %s; ask which player they meant", name, strings.Join(found, " or "))
+		}
+	}
+	if !r.synced {
+		return name, nil
+	}
+	return "", fmt.Errorf("%s is not online right now (online: %s)", name, r.summaryLocked())
+}
+
+// Context is the one-line roster summary added to the system prompt.
+func (r *playerRoster) Context() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.players) == 0 && !r.synced {
+		return ""
+	}
+	return "Players online right now: " + r.summaryLocked() + ". Only these players can be teleported to or targeted by tools."
+}
+
+func (r *playerRoster) summaryLocked() string {
+	if len(r.players) == 0 {
+		return "nobody"
+	}
+	names := make([]string, 0, len(r.players))
+	for _, name := range r.players {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// parseListReply extracts player names from `list` output; ok is false when the text
+// is not a list reply at all.
+func parseListReply(reply string) ([]string, bool) {
+	m := listReplyPattern.FindStringSubmatch(strings.TrimSpace(colorCodePattern.ReplaceAllString(reply, "")))
+	if m == nil {
+		return nil, false
+	}
+	var players []string
+	for _, name := range strings.Split(m[1], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			players = append(players, name)
+		}
+	}
+	return players, true
+}
+
+// updateRoster applies a server event to the roster. Start and stop lines mean nobody is
+// online, which also makes the roster authoritative.
+func updateRoster(cfg Config, evt ChatEvent) {
+	switch evt.Kind {
+	case EventChat, EventJoin, EventDeath, EventAdvancement:
+		cfg.Roster.Join(evt.Player)
+	case EventQuit:
+		cfg.Roster.Leave(evt.Player)
+	case EventPlayerList:
+		players, _ := parseListReply(evt.Text)
+		cfg.Roster.Replace(players)
+	case EventServerStart, EventServerStop:
+		cfg.Roster.Replace(nil)
+	}
+}
+
+// rosterSyncLoop runs `list` every interval to correct drift (missed lines, a bot
+// restart mid-session). RCON returns the list directly; with screen the reply shows up
+// in the log and arrives as an EventPlayerList instead.
+func rosterSyncLoop(ctx context.Context, cfg Config, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		reply, err := runConsoleCommand(ctx, cfg, "list")
+		if err != nil {
+			log.Printf("roster sync error: %v", err)
+		} else if players, ok := parseListReply(reply); ok {
+			cfg.Roster.Replace(players)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// editDistance is the Levenshtein distance, used to forgive small typos in names.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
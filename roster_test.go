@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlayerRosterResolve(t *testing.T) {
+	roster := newPlayerRoster()
+	roster.Replace([]string{"Steve_2011", "Stevenson", "Alex", "Alexandra", "Notch", "Dinnerbone", "Inker", "Jebediah", "TheJeb", "CaptainSparklez"})
+
+	cases := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{"exact", "Notch", "Notch", ""},
+		{"exact ignores case", "nOTCH", "Notch", ""},
+		{"exact beats prefix", "alex", "Alex", ""},
+		{"unique prefix", "steve_", "Steve_2011", ""},
+		{"prefix beats substring", "jeb", "Jebediah", ""},
+		{"unique substring", "sparkle", "CaptainSparklez", ""},
+		{"substring beats typo", "inner", "Dinnerbone", ""},
+		{"one typo", "Nitch", "Notch", ""},
+		{"two typos in a long name", "dinerbon", "Dinnerbone", ""},
+		{"two typos in a short name", "Ntoch", "", "not online"},
+		{"ambiguous prefix", "steve", "", `"steve" could mean Steve_2011 or Stevenson`},
+		{"ambiguous substring", "lex", "", "could mean Alex or Alexandra"},
+		{"offline", "Herobrine", "", "Herobrine is not online right now"},
+		{"selector", "@a", "", "target selectors"},
+		{"not a name", "Steve op Alex", "", "not a valid Minecraft username"},
+	}
+	for _, tc := range cases {
+		got, err := roster.Resolve(tc.input)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: Resolve(%q) = %q, %v; want error %q", tc.name, tc.input, got, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%s: Resolve(%q) = %q, %v; want %q", tc.name, tc.input, got, err, tc.want)
+		}
+	}
+}
+
+func TestPlayerRosterPassesThroughUntilSynced(t *testing.T) {
+	roster := newPlayerRoster()
+	roster.Join("Steve_2011")
+
+	cases := []struct {
+		name    string
+		roster  *playerRoster
+		input   string
+		want    string
+		wantErr string
+	}{
+		{"known player still matches", roster, "steve", "Steve_2011", ""},
+		{"unknown player passes through", roster, "Herobrine", "Herobrine", ""},
+		{"invalid name still rejected", roster, "@p", "", "target selectors"},
+		{"nil roster passes through", nil, "Herobrine", "Herobrine", ""},
+		{"nil roster still validates", nil, "Steve;op", "", "not a valid Minecraft username"},
+	}
+	for _, tc := range cases {
+		got, err := tc.roster.Resolve(tc.input)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: Resolve(%q) = %q, %v; want error %q", tc.name, tc.input, got, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%s: Resolve(%q) = %q, %v; want %q", tc.name, tc.input, got, err, tc.want)
+		}
+	}
+
+	roster.Replace([]string{"Steve_2011"})
+	if _, err := roster.Resolve("Herobrine"); err == nil {
+		t.Fatal("a synced roster let an offline player through")
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errLogClosed is returned for entries written after shutdown began.
+var errLogClosed = errors.New("log is closed")
+
+// rotatingLog owns one JSONL file: the interaction log, or the chat transcript. Every
+// goroutine hands its line to one writer goroutine, which keeps the file open, rotates it
+// by size and by day, gzips old segments, and deletes segments older than the retention
+// window.
+//
+// 🎓 LEARNING NOTE: Many workers answer campers at once. If each one opened the file and
+// wrote to it, lines could interleave. Instead they all drop their line into a channel
+// (a mailbox) and a single goroutine does all the writing - no locks around the file!
+type rotatingLog struct {
+	path      string
+	maxBytes  int64
+	daily     bool
+	compress  bool
+	retention time.Duration
+	now       func() time.Time
+
+	mu      sync.RWMutex // guards closed against sends on a closed channel
+	closed  bool
+	entries chan logEntry
+	done    chan struct{}
+
+	// Owned by the writer goroutine.
+	file    *os.File
+	size    int64
+	day     string
+	lastLog time.Time
+}
+
+// logEntry is one queued line and the moment it was logged, which decides the day it is
+// filed under even if the writer gets to it after midnight.
+type logEntry struct {
+	line []byte
+	at   time.Time
+}
+
+// newRotatingLog opens the log and starts its writer. An empty path disables logging
+// and returns nil, which every method accepts.
+func newRotatingLog(path string, maxBytes int64, daily, compress bool, retention time.Duration) (*rotatingLog, error) {
+	return openRotatingLog(path, maxBytes, daily, compress, retention, time.Now)
+}
+
+// openRotatingLog is newRotatingLog with the clock passed in, so tests can cross
+// midnight or age segments past the retention window without waiting.
+func openRotatingLog(path string, maxBytes int64, daily, compress bool, retention time.Duration, now func() time.Time) (*rotatingLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+	l := &rotatingLog{
+		path:      path,
+		maxBytes:  maxBytes,
+		daily:     daily,
+		compress:  compress,
+		retention: retention,
+		now:       now,
+		entries:   make(chan logEntry, 256),
+		done:      make(chan struct{}),
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	l.prune(now())
+	go l.run()
+	return l, nil
+}
+
+// Write queues one JSON line. It only blocks when the writer is far behind.
+func (l *rotatingLog) Write(line []byte) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return errLogClosed
+	}
+	l.entries <- logEntry{line: append(line, '\n'), at: l.now()}
+	return nil
+}
+
+// Close flushes queued lines and closes the file.
+func (l *rotatingLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.entries)
+	}
+	l.mu.Unlock()
+	<-l.done
+	return nil
+}
+
+// run is the single writer goroutine. An hourly tick enforces retention even when the
+// camp is quiet.
+func (l *rotatingLog) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case entry, ok := <-l.entries:
+			if !ok {
+				if err := l.file.Close(); err != nil {
+					log.Printf("%s close error: %v", l.path, err)
+				}
+				return
+			}
+			if err := l.write(entry.line, entry.at); err != nil {
+				log.Printf("%s write error: %v", l.path, err)
+			}
+		case <-ticker.C:
+			l.prune(l.now())
+		}
+	}
+}
+
+// open opens (or creates) the live file and remembers its size and the day it belongs
+// to, so a file left over from yesterday is rotated on the first write today.
+func (l *rotatingLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file, l.size = file, info.Size()
+	l.lastLog = info.ModTime()
+	l.day = l.lastLog.Format("2006-01-02")
+	return nil
+}
+
+func (l *rotatingLog) write(line []byte, now time.Time) error {
+	if l.size > 0 && l.needsRotation(int64(len(line)), now) {
+		if err := l.rotate(now); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	l.lastLog = now
+	l.day = now.Format("2006-01-02")
+	return err
+}
+
+func (l *rotatingLog) needsRotation(next int64, now time.Time) bool {
+	if l.maxBytes > 0 && l.size+next > l.maxBytes {
+		return true
+	}
+	return l.daily && now.Format("2006-01-02") != l.day
+}
+
+// rotate renames the live file to a timestamped segment (named and dated after its last
+// write), compresses it when configured, reopens a fresh file, and prunes old segments.
+func (l *rotatingLog) rotate(now time.Time) error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	segment := l.segmentName(l.lastLog)
+	if err := os.Rename(l.path, segment); err != nil {
+		return err
+	}
+	if err := os.Chtimes(segment, l.lastLog, l.lastLog); err != nil {
+		return err
+	}
+	if err := l.open(); err != nil {
+		return err
+	}
+	if l.compress {
+		if err := gzipFile(segment); err != nil {
+			log.Printf("%s gzip error: %v", segment, err)
+		}
+	}
+	l.prune(now)
+	return nil
+}
+
+// segmentName builds chat_history-20240601-235959.log, adding -1, -2... if that name
+// (or its .gz) already exists.
+func (l *rotatingLog) segmentName(t time.Time) string {
+	ext := filepath.Ext(l.path)
+	base := strings.TrimSuffix(l.path, ext)
+	stamp := t.Format("20060102-150405")
+	name := fmt.Sprintf("%s-%s%s", base, stamp, ext)
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%s-%d%s", base, stamp, i, ext)
+	}
+	return name
+}
+
+// prune deletes rotated segments whose last write is older than the retention window.
+// The live file is never touched.
+func (l *rotatingLog) prune(now time.Time) {
+	if l.retention <= 0 {
+		return
+	}
+	ext := filepath.Ext(l.path)
+	pattern := strings.TrimSuffix(l.path, ext) + "-*" + ext + "*"
+	segments, err := filepath.Glob(pattern)
+	if err != nil {
+		return
+	}
+	for _, segment := range segments {
+		info, err := os.Stat(segment)
+		if err != nil || info.IsDir() || now.Sub(info.ModTime()) < l.retention {
+			continue
+		}
+		if err := os.Remove(segment); err != nil {
+			log.Printf("%s retention error: %v", segment, err)
+			continue
+		}
+		log.Printf("[LOG] removed %s (older than %s)", segment, l.retention)
+	}
+}
+
+// gzipFile compresses path to path.gz, keeps the original modification time so
+// retention still counts from the last entry, and removes the original.
+func gzipFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	zw.Name = filepath.Base(path)
+	zw.ModTime = info.ModTime()
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	if err := os.Chtimes(out.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
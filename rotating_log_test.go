@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testClock is a clock the test moves by hand.
+type testClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *testClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// readLogFile returns a log file's lines, decompressing .gz segments.
+func readLogFile(t *testing.T, path string) []string {
+	t.Helper()
+	var lines []string
+	err := scanLogSegment(path, func(line []byte) bool {
+		lines = append(lines, string(line))
+		return true
+	})
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return lines
+}
+
+// rotatedSegments lists the rotated segments of path, oldest first, without the live file.
+func rotatedSegments(t *testing.T, path string) []string {
+	t.Helper()
+	segments, err := logSegments(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(segments); n > 0 && segments[n-1] == path {
+		segments = segments[:n-1]
+	}
+	return segments
+}
+
+func quietLogs(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+func TestRotatingLogRotatesBySize(t *testing.T) {
+	quietLogs(t)
+	path := filepath.Join(t.TempDir(), "chat.log")
+	clock := &testClock{t: time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)}
+	l, err := openRotatingLog(path, 30, false, false, 0, clock.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		if err := l.Write([]byte(fmt.Sprintf("line-%06d", i))); err != nil { // 12 bytes with the newline
+			t.Fatal(err)
+		}
+		clock.advance(time.Second)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	segments := rotatedSegments(t, path)
+	want := []string{
+		strings.TrimSuffix(path, ".log") + "-20240601-120001.log",
+		strings.TrimSuffix(path, ".log") + "-20240601-120003.log",
+	}
+	if fmt.Sprint(segments) != fmt.Sprint(want) {
+		t.Fatalf("segments = %v, want %v (named after their last write)", segments, want)
+	}
+	var all []string
+	for _, segment := range segments {
+		lines := readLogFile(t, segment)
+		if len(lines) != 2 {
+			t.Errorf("%s holds %q, want two 12-byte lines under the 30-byte cap", segment, lines)
+		}
+		all = append(all, lines...)
+	}
+	all = append(all, readLogFile(t, path)...)
+	if fmt.Sprint(all) != "[line-000001 line-000002 line-000003 line-000004 line-000005]" {
+		t.Fatalf("lines across segments = %q, want all five in order", all)
+	}
+}
+
+func TestRotatingLogRotatesDailyAndCompresses(t *testing.T) {
+	quietLogs(t)
+	path := filepath.Join(t.TempDir(), "chat.log")
+	clock := &testClock{t: time.Date(2024, 6, 1, 23, 59, 0, 0, time.Local)}
+	l, err := openRotatingLog(path, 0, true, true, 0, clock.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Write([]byte("before midnight"))
+	clock.advance(30 * time.Second)
+	l.Write([]byte("still june first"))
+	clock.advance(time.Minute)
+	l.Write([]byte("after midnight"))
+	l.Close()
+
+	segment := strings.TrimSuffix(path, ".log") + "-20240601-235930.log"
+	if fileExists(segment) {
+		t.Errorf("%s was left uncompressed", segment)
+	}
+	if got := rotatedSegments(t, path); len(got) != 1 || got[0] != segment+".gz" {
+		t.Fatalf("segments = %v, want only %s.gz", got, segment)
+	}
+	if got := readLogFile(t, segment+".gz"); fmt.Sprint(got) != "[before midnight still june first]" {
+		t.Errorf("June 1 segment = %q", got)
+	}
+	if got := readLogFile(t, path); fmt.Sprint(got) != "[after midnight]" {
+		t.Errorf("live file = %q", got)
+	}
+	info, err := os.Stat(segment + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := clock.t.Add(-time.Minute); !info.ModTime().Equal(want) {
+		t.Errorf("segment dated %v, want its last write %v so retention counts from there", info.ModTime(), want)
+	}
+}
+
+func TestRotatingLogPrunesOldSegments(t *testing.T) {
+	quietLogs(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "chat.log")
+	start := time.Date(2024, 6, 10, 9, 0, 0, 0, time.Local)
+	clock := &testClock{t: start}
+	aged := func(name string, age time.Duration) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte("old\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, start.Add(-age), start.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	ancient := aged("chat-20240531-090000.log.gz", 10*24*time.Hour)
+	recent := aged("chat-20240609-090000.log.gz", 24*time.Hour)
+	unrelated := aged("notes.txt", 10*24*time.Hour)
+
+	l, err := openRotatingLog(path, 0, true, false, 4*24*time.Hour, clock.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Write([]byte("june tenth"))
+	clock.advance(3*24*time.Hour + time.Hour)
+	l.Write([]byte("june thirteenth")) // rotates, then prunes as of June 13
+	l.Close()
+
+	if fileExists(ancient) {
+		t.Error("segment older than the retention window survived startup")
+	}
+	if fileExists(recent) {
+		t.Error("segment that aged past the retention window survived rotation")
+	}
+	if !fileExists(unrelated) {
+		t.Error("pruning removed a file that is not a log segment")
+	}
+	if got := rotatedSegments(t, path); len(got) != 1 || !strings.HasSuffix(got[0], "-20240610-090000.log") {
+		t.Errorf("segments = %v, want only the June 10 segment", got)
+	}
+	if !fileExists(path) {
+		t.Error("the live file was pruned")
+	}
+}
+
+func TestRotatingLogCloseDrainsQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.log")
+	l, err := newRotatingLog(path, 0, false, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// More lines than the channel buffers, so Close must wait for the writer to catch up.
+	const lines = 1000
+	for i := 0; i < lines; i++ {
+		if err := l.Write([]byte(fmt.Sprintf(`{"n":%d}`, i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got := readLogFile(t, path)
+	if len(got) != lines || got[lines-1] != fmt.Sprintf(`{"n":%d}`, lines-1) {
+		t.Fatalf("file holds %d lines after Close, want %d", len(got), lines)
+	}
+	if err := l.Write([]byte("late")); err != errLogClosed {
+		t.Fatalf("write after Close = %v, want errLogClosed", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("second Close = %v", err)
+	}
+
+	var disabled *rotatingLog
+	if err := disabled.Write([]byte("x")); err != nil || disabled.Close() != nil {
+		t.Fatal("a disabled (nil) log should accept writes and Close")
+	}
+}
+
+func TestLogSegmentsOrdersSameSecondRotations(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "chat.log")
+	names := []string{
+		"chat-20240601-120000-10.log.gz",
+		"chat-20240601-120000-2.log",
+		"chat-20240601-120000.log.gz",
+		"chat-20240601-120000-1.log.gz",
+		"chat-20240531-235959-3.log.gz",
+		"chat.log",
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	segments, err := logSegments(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, segment := range segments {
+		got = append(got, filepath.Base(segment))
+	}
+	want := "[chat-20240531-235959-3.log.gz chat-20240601-120000.log.gz chat-20240601-120000-1.log.gz chat-20240601-120000-2.log chat-20240601-120000-10.log.gz chat.log]"
+	if fmt.Sprint(got) != want {
+		t.Fatalf("segments = %v, want %s", got, want)
+	}
+}
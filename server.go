@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"context"
 	"errors"
-	"fmt"
 	"io"
 	"log"
 	"os"
@@ -12,21 +11,67 @@ import (
 	"time"
 )
 
-// ChatEvent represents one parsed Minecraft chat line with metadata so the responder
-// can track the player, text, and arrival time.
+// EventKind labels what happened on the server so the main loop can dispatch on it.
+// The zero value is EventChat so plain chat events stay the common case.
+type EventKind int
+
+const (
+	EventChat EventKind = iota
+	EventJoin
+	EventQuit
+	EventDeath
+	EventAdvancement
+	EventServerStart
+	EventServerStop
+	EventPlayerList
+)
+
+// String returns the lowercase label used in logs and interaction records.
+func (k EventKind) String() string {
+	switch k {
+	case EventChat:
+		return "chat"
+	case EventJoin:
+		return "join"
+	case EventQuit:
+		return "quit"
+	case EventDeath:
+		return "death"
+	case EventAdvancement:
+		return "advancement"
+	case EventServerStart:
+		return "server_start"
+	case EventServerStop:
+		return "server_stop"
+	case EventPlayerList:
+		return "player_list"
+	default:
+		return "unknown"
+	}
+}
+
+// ChatEvent represents one parsed Minecraft log event with metadata so the responder
+// can track the kind, player, text, and arrival time. Text holds the chat message or
+// the raw server message; Detail carries the death cause or advancement title.
 type ChatEvent struct {
+	Kind   EventKind
 	Player string
 	Text   string
+	Detail string
 	Time   time.Time
+	Line   int64 // Transcript line ID, set by the main loop; 0 without a transcript
 }
 
-// watchChat tails the live Minecraft log file and emits ChatEvent structs whenever an async
-// chat line appears. It handles log rotations by reopening when offsets shrink.
-func watchChat(ctx context.Context, path string, out chan<- ChatEvent) error {
+// watchChat tails the live Minecraft log file and emits ChatEvent structs whenever a chat,
+// join, quit, death, advancement, or lifecycle line appears. It handles log rotations by
+// reopening when offsets shrink, re-detecting the log format each time it attaches. When
+// the consumer falls behind and out is full, the stall is counted in stats.
+func watchChat(ctx context.Context, path, formatName string, out chan<- ChatEvent, stats *pipelineStats) error {
 	var (
 		file   *os.File
 		reader *bufio.Reader
 		offset int64
+		format logFormat
 	)
 	openFile := func() error {
 		if file != nil {
@@ -44,7 +89,8 @@ func watchChat(ctx context.Context, path string, out chan<- ChatEvent) error {
 		file = f
 		reader = bufio.NewReader(file)
 		offset = pos
-		log.Printf("Attached to log %s at %.0f bytes", path, float64(pos))
+		format = resolveLogFormat(formatName, path)
+		log.Printf("Attached to log %s at %.0f bytes (format: %s)", path, float64(pos), format.Name)
 		return nil
 	}
 	if err := openFile(); err != nil {
@@ -72,7 +118,14 @@ func watchChat(ctx context.Context, path string, out chan<- ChatEvent) error {
 				return err
 			}
 			offset += int64(len(line))
-			if evt, ok := parseChatLine(strings.TrimRight(line, "\n")); ok {
+			if evt, ok := format.parseLine(strings.TrimRight(line, "\r\n")); ok {
+				stats.eventsRead.Add(1)
+				select {
+				case out <- evt:
+					continue
+				default:
+					stats.channelFull.Add(1)
+				}
 				select {
 				case out <- evt:
 				case <-ctx.Done():
@@ -84,35 +137,110 @@ func watchChat(ctx context.Context, path string, out chan<- ChatEvent) error {
 	}
 }
 
-// parseChatLine extracts `<player> message` pairs from async chat lines and timestamps them.
-// It returns false when the line is unrelated so the caller can skip it quickly.
-func parseChatLine(line string) (ChatEvent, bool) {
-	if !strings.Contains(line, "Async Chat Thread") {
-		return ChatEvent{}, false
+// classifyServerMessage turns the message part of a main-thread line into an event.
+func classifyServerMessage(message string) (ChatEvent, bool) {
+	now := time.Now()
+	if strings.HasPrefix(message, "Done (") && strings.Contains(message, "For help") {
+		return ChatEvent{Kind: EventServerStart, Text: message, Time: now}, true
 	}
-	marker := "]: <"
-	idx := strings.Index(line, marker)
-	if idx == -1 {
-		return ChatEvent{}, false
+	if strings.HasPrefix(message, "Stopping server") || strings.HasPrefix(message, "Stopping the server") {
+		return ChatEvent{Kind: EventServerStop, Text: message, Time: now}, true
+	}
+	if _, ok := parseListReply(message); ok {
+		return ChatEvent{Kind: EventPlayerList, Text: message, Time: now}, true
 	}
-	rest := line[idx+len(marker):]
-	end := strings.Index(rest, "> ")
-	if end == -1 {
+	player, rest, ok := strings.Cut(message, " ")
+	if !ok || !isPlausiblePlayerName(player) {
 		return ChatEvent{}, false
 	}
-	player := rest[:end]
-	message := strings.TrimSpace(rest[end+2:])
-	return ChatEvent{Player: player, Text: message, Time: time.Now()}, true
+	switch rest {
+	case "joined the game":
+		return ChatEvent{Kind: EventJoin, Player: player, Text: message, Time: now}, true
+	case "left the game":
+		return ChatEvent{Kind: EventQuit, Player: player, Text: message, Time: now}, true
+	}
+	for _, phrase := range advancementPhrases {
+		if strings.HasPrefix(rest, phrase) {
+			title := strings.Trim(strings.TrimSpace(strings.TrimPrefix(rest, phrase)), "[]")
+			return ChatEvent{Kind: EventAdvancement, Player: player, Text: message, Detail: title, Time: now}, true
+		}
+	}
+	if isDeathMessage(rest) {
+		return ChatEvent{Kind: EventDeath, Player: player, Text: message, Detail: rest, Time: now}, true
+	}
+	return ChatEvent{}, false
+}
+
+// advancementPhrases cover regular advancements, challenges, and goals.
+var advancementPhrases = []string{
+	"has made the advancement ",
+	"has completed the challenge ",
+	"has reached the goal ",
+}
+
+// deathPhrases are the openings of vanilla death messages once the victim's name is
+// removed ("was slain by Zombie", "fell from a high place", "drowned").
+var deathPhrases = []string{
+	"was ", "drowned", "died", "blew up", "burned to death", "fell ",
+	"hit the ground too hard", "starved to death", "suffocated", "tried to swim in lava",
+	"went up in flames", "went off with a bang", "walked into", "withered away",
+	"experienced kinetic energy", "froze to death", "discovered the floor was lava",
+	"didn't want to live", "left the confines of this world", "burnt to a crisp",
+}
+
+// isDeathMessage matches the remainder of a server line against known death phrasing,
+// excluding moderation notices that happen to share the "was" opening.
+func isDeathMessage(rest string) bool {
+	if strings.HasPrefix(rest, "was kicked") || strings.HasPrefix(rest, "was banned") {
+		return false
+	}
+	for _, phrase := range deathPhrases {
+		if strings.HasPrefix(rest, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPlausiblePlayerName accepts Minecraft-style usernames (letters, digits, underscore)
+// so connection lines such as "Steve[/127.0.0.1:5555] logged in" are ignored.
+func isPlausiblePlayerName(name string) bool {
+	if len(name) < 2 || len(name) > 16 {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
 }
 
 // shouldRespond evaluates the incoming chat event and decides whether Alfred should reply,
-// returning the user-facing prompt plus whether an alert was involved. It encapsulates
-// all heuristics so the main loop simply reacts to the boolean decision.
-func shouldRespond(cfg Config, evt ChatEvent) (string, bool, bool) {
+// returning the user-facing prompt plus the moderation verdict when an alert phrase was
+// involved. Moderation runs first so "Alfred you idiot" still earns a reminder rather than
+// a cheerful answer. It encapsulates all heuristics so the main loop simply reacts to the
+// boolean decision.
+func shouldRespond(cfg Config, evt ChatEvent) (string, bool, *moderationVerdict) {
+	if cfg.EnableAlertTrigger {
+		if verdict, ok := cfg.Moderation.Evaluate(evt.Text); ok {
+			// Toxicity or safety phrases get the category's policy: a kindness reminder,
+			// lightning, a staff alert, or a mute.
+			return verdict.Prompt(evt.Text), true, &verdict
+		}
+	}
+	prompt, ok := conversationPrompt(cfg, evt)
+	return prompt, ok, nil
+}
+
+// conversationPrompt applies the non-moderation triggers: name mentions, the prefix,
+// teleport requests, and questions. It is also used when the classifier overrules a
+// keyword hit, so the message is then treated like any other chat.
+func conversationPrompt(cfg Config, evt ChatEvent) (string, bool) {
 	lower := strings.ToLower(evt.Text)
 	if cfg.EnableNameTrigger && strings.Contains(lower, strings.ToLower(cfg.RobotName)) {
 		// Treat any mention of Alfred's name as a direct question.
-		return evt.Text, true, false
+		return evt.Text, true
 	}
 	if cfg.EnablePrefixTrigger && strings.HasPrefix(lower, strings.ToLower(cfg.TriggerWord)) {
 		// Strip the trigger prefix (!bot hi) before routing to the LLM.
@@ -120,19 +248,15 @@ func shouldRespond(cfg Config, evt ChatEvent) (string, bool, bool) {
 		if trimmed == "" {
 			trimmed = "Hello!"
 		}
-		return trimmed, true, false
-	}
-	if cfg.EnableAlertTrigger && containsAny(lower, cfg.AlertWords) {
-		// Toxicity or safety keywords generate a kindness reminder and lightning cue.
-		return fmt.Sprintf("Gently remind about kindness and safety. Conversation snippet: %s", evt.Text), true, true
+		return trimmed, true
 	}
 	if cfg.EnableToolUse && teleportRegex.MatchString(evt.Text) {
-		return evt.Text, true, false
+		return evt.Text, true
 	}
 	if cfg.EnableQuestionTrigger && (strings.Contains(evt.Text, "?") || containsAny(lower, cfg.EngageWords)) {
-		return evt.Text, true, false
+		return evt.Text, true
 	}
-	return "", false, false
+	return "", false
 }
 
 // containsAny performs a substring scan for the provided keywords and returns true on match.
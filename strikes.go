@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// strikeRecord is one moderation hit counted against a player.
+type strikeRecord struct {
+	Time     time.Time `json:"time"`
+	Category string    `json:"category"`
+	Match    string    `json:"match"`
+}
+
+// strikeLedger remembers recent moderation hits per player so repeat offenders get
+// firmer consequences. Strikes decay: anything older than the decay window no longer
+// counts, so one bad afternoon is not held against a camper all summer.
+//
+// 🎓 LEARNING NOTE: This is like a referee's yellow cards that wear off after a day.
+// First card = a reminder, a few more = lightning, too many = time out!
+type strikeLedger struct {
+	mu      sync.Mutex
+	decay   time.Duration
+	path    string
+	players map[string][]strikeRecord
+}
+
+// newStrikeLedger builds the ledger and reloads the file saved by a previous run when a
+// path is configured. A missing file is fine; a corrupt one is reported.
+func newStrikeLedger(path string, decay time.Duration) (*strikeLedger, error) {
+	l := &strikeLedger{decay: decay, path: path, players: make(map[string][]strikeRecord)}
+	if path == "" {
+		return l, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &l.players); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Add records a strike and returns how many active strikes the player now has.
+func (l *strikeLedger) Add(player string, verdict moderationVerdict, now time.Time) int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := strikeKey(player)
+	active := l.activeLocked(key, now)
+	active = append(active, strikeRecord{Time: now, Category: string(verdict.Category), Match: verdict.Match})
+	l.players[key] = active
+	if err := l.saveLocked(); err != nil {
+		log.Printf("strike save error: %v", err)
+	}
+	return len(active)
+}
+
+// Active returns the player's strikes that have not decayed yet, oldest first.
+func (l *strikeLedger) Active(player string, now time.Time) []strikeRecord {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]strikeRecord(nil), l.activeLocked(strikeKey(player), now)...)
+}
+
+// Clear forgives every strike the player has and returns how many were removed.
+func (l *strikeLedger) Clear(player string) int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := strikeKey(player)
+	n := len(l.players[key])
+	delete(l.players, key)
+	if err := l.saveLocked(); err != nil {
+		log.Printf("strike save error: %v", err)
+	}
+	return n
+}
+
+func (l *strikeLedger) activeLocked(key string, now time.Time) []strikeRecord {
+	records := l.players[key]
+	if l.decay <= 0 {
+		return records
+	}
+	kept := records[:0]
+	for _, r := range records {
+		if now.Sub(r.Time) <= l.decay {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == 0 {
+		delete(l.players, key)
+		return nil
+	}
+	l.players[key] = kept
+	return kept
+}
+
+// saveLocked writes the ledger atomically (temp file + rename), like conversation memory.
+func (l *strikeLedger) saveLocked() error {
+	if l.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(l.players)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), ".strikes-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
+
+func strikeKey(player string) string {
+	return strings.ToLower(strings.TrimSpace(player))
+}
+
+// strikeTier adds actions once a player reaches Threshold active strikes.
+type strikeTier struct {
+	Threshold int
+	Actions   []moderationAction
+}
+
+// parseStrikeTiers reads "1=reminder,2=reminder+lightning,3=mute,4=kick+page" into tiers
+// sorted by threshold.
+func parseStrikeTiers(spec string) ([]strikeTier, error) {
+	var tiers []strikeTier
+	for _, entry := range parseCSV(spec) {
+		count, list, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("strike tier %q must look like count=action+action", entry)
+		}
+		threshold, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil || threshold < 1 {
+			return nil, fmt.Errorf("strike tier %q needs a positive strike count", entry)
+		}
+		actions, err := parseModerationActions(list)
+		if err != nil {
+			return nil, fmt.Errorf("strike tier %d: %w", threshold, err)
+		}
+		tiers = append(tiers, strikeTier{Threshold: threshold, Actions: actions})
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Threshold < tiers[j].Threshold })
+	return tiers, nil
+}
+
+// strikeActions returns the actions of the highest tier the strike count has reached.
+func strikeActions(tiers []strikeTier, strikes int) []moderationAction {
+	var actions []moderationAction
+	for _, tier := range tiers {
+		if strikes >= tier.Threshold {
+			actions = tier.Actions
+		}
+	}
+	return actions
+}
+
+// recordStrike counts the verdict against the player and folds the reached tier's
+// actions into the category policy. Self-harm is never a strike: a camper asking for
+// help is not misbehaving.
+func recordStrike(cfg Config, player string, verdict moderationVerdict, now time.Time) moderationVerdict {
+	if cfg.Strikes == nil || verdict.Category == categorySelfHarm {
+		return verdict
+	}
+	verdict.Strikes = cfg.Strikes.Add(player, verdict, now)
+	merged := append([]moderationAction(nil), verdict.Actions...)
+	for _, action := range strikeActions(cfg.StrikeTiers, verdict.Strikes) {
+		if !verdict.Has(action) {
+			merged = append(merged, action)
+		}
+	}
+	verdict.Actions = merged
+	return verdict
+}
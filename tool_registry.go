@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// toolCategory groups tools so one config toggle can enable or disable a family.
+type toolCategory string
+
+const (
+	toolCategoryMovement  toolCategory = "movement"
+	toolCategoryWorld     toolCategory = "world"
+	toolCategoryEasterEgg toolCategory = "easter_egg"
+	toolCategoryCustom    toolCategory = "custom"
+)
+
+// Tool is one capability the model may call. Each tool owns its schema, argument
+// checks, and console work, so adding a tool means writing one type (or one
+// builtinTool entry) and registering it.
+type Tool interface {
+	// Definition is the schema sent to the model; cfg supplies dynamic parts such as
+	// the waypoint enum.
+	Definition(cfg Config) ToolDefinition
+	// Validate rejects arguments that do not match the schema before anything runs.
+	Validate(cfg Config, arguments string) error
+	// Execute performs the action and returns the text the model sees.
+	Execute(ctx context.Context, cfg Config, evt ChatEvent, call ToolCall) (string, error)
+	// Category decides which MCCHATBOT_ENABLE_* toggle controls the tool.
+	Category() toolCategory
+	// RequiresConsent marks tools that act on another player, who must agree first.
+	RequiresConsent() bool
+}
+
+// builtinTool adapts the hand-written definition and executor functions to Tool.
+// Arguments are checked against the definition's own schema.
+type builtinTool struct {
+	definition func(cfg Config) ToolDefinition
+	execute    ToolExecutor
+	category   toolCategory
+	consent    bool
+}
+
+func (t builtinTool) Definition(cfg Config) ToolDefinition { return t.definition(cfg) }
+func (t builtinTool) Category() toolCategory               { return t.category }
+func (t builtinTool) RequiresConsent() bool                { return t.consent }
+
+func (t builtinTool) Validate(cfg Config, arguments string) error {
+	return validateToolArguments(t.definition(cfg), arguments)
+}
+
+func (t builtinTool) Execute(ctx context.Context, cfg Config, evt ChatEvent, call ToolCall) (string, error) {
+	return t.execute(ctx, cfg, evt, call)
+}
+
+// static wraps a fixed definition function for builtinTool.
+func static(definition func() ToolDefinition) func(Config) ToolDefinition {
+	return func(Config) ToolDefinition { return definition() }
+}
+
+// builtinTools lists every tool compiled into Alfred.
+func builtinTools() []Tool {
+	return []Tool{
+		builtinTool{
+			definition: func(cfg Config) ToolDefinition { return teleportToolDefinition(cfg.Waypoints.Names()) },
+			execute:    executeTeleportTool,
+			category:   toolCategoryMovement,
+			consent:    true,
+		},
+		builtinTool{definition: static(timeToolDefinition), execute: executeTimeTool, category: toolCategoryWorld},
+		builtinTool{definition: static(weatherToolDefinition), execute: executeWeatherTool, category: toolCategoryWorld},
+		builtinTool{definition: static(floatingCatToolDefinition), execute: executeFloatingCatTool, category: toolCategoryEasterEgg},
+		builtinTool{definition: static(tinySlimeToolDefinition), execute: executeTinySlimeTool, category: toolCategoryEasterEgg},
+		builtinTool{definition: static(skyliftToolDefinition), execute: executeSkyliftTool, category: toolCategoryEasterEgg},
+		builtinTool{definition: static(cookieDropToolDefinition), execute: executeCookieDropTool, category: toolCategoryEasterEgg},
+		builtinTool{definition: static(villagerHmmToolDefinition), execute: executeVillagerHmmTool, category: toolCategoryEasterEgg},
+		builtinTool{definition: static(fireworkToolDefinition), execute: executeFireworkTool, category: toolCategoryEasterEgg},
+		builtinTool{definition: static(glowAuraToolDefinition), execute: executeGlowAuraTool, category: toolCategoryEasterEgg},
+		builtinTool{definition: static(heartParticlesToolDefinition), execute: executeHeartParticlesTool, category: toolCategoryEasterEgg},
+		builtinTool{definition: static(poofToolDefinition), execute: executePoofTool, category: toolCategoryEasterEgg},
+		builtinTool{definition: static(golemGuardToolDefinition), execute: executeGolemGuardTool, category: toolCategoryEasterEgg},
+	}
+}
+
+// toolRegistry holds every known tool by name. It decides which tools a player gets,
+// validates arguments, applies the tool policy, and logs each call the same way.
+//
+// 🎓 LEARNING NOTE: A "registry" is a phone book for tools. Instead of keeping a list of
+// definitions in one place and a map of functions in another (and hoping they match),
+// each tool carries everything about itself and signs in once.
+type toolRegistry struct {
+	tools  []Tool
+	byName map[string]Tool
+}
+
+// newToolRegistry indexes tools by name, refusing duplicates so a custom tool can never
+// shadow a built-in one.
+func newToolRegistry(cfg Config, tools ...Tool) (*toolRegistry, error) {
+	r := &toolRegistry{byName: make(map[string]Tool, len(tools))}
+	for _, tool := range tools {
+		name := tool.Definition(cfg).Function.Name
+		if _, dup := r.byName[name]; dup {
+			return nil, fmt.Errorf("tool %q is registered twice", name)
+		}
+		r.byName[name] = tool
+		r.tools = append(r.tools, tool)
+	}
+	return r, nil
+}
+
+// enabled reports whether config turns on the tool's category.
+func (r *toolRegistry) enabled(cfg Config, tool Tool) bool {
+	if !cfg.EnableToolUse {
+		return false
+	}
+	switch tool.Category() {
+	case toolCategoryWorld:
+		return cfg.EnableWorldTool
+	case toolCategoryEasterEgg:
+		return cfg.EnableEasterEggs
+	}
+	return true
+}
+
+// Available returns the tools this player may use right now, in registration order, so
+// the model never hears about tools that are switched off or forbidden by policy.
+func (r *toolRegistry) Available(cfg Config, player string) []Tool {
+	if r == nil {
+		return nil
+	}
+	var tools []Tool
+	for _, tool := range r.tools {
+		if r.enabled(cfg, tool) && cfg.ToolPolicy.Allowed(player, tool.Definition(cfg).Function.Name) {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+// Definitions renders the schemas for a tool list. Consent-gated tools say so, so the
+// model can warn the camper that the other player has to agree.
+func (r *toolRegistry) Definitions(cfg Config, tools []Tool) []ToolDefinition {
+	defs := make([]ToolDefinition, 0, len(tools))
+	for _, tool := range tools {
+		def := tool.Definition(cfg)
+		if tool.RequiresConsent() && cfg.Consent != nil {
+			def.Function.Description += " Another player has to accept before it happens."
+		}
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// Run executes one tool call from the model: look the tool up among the ones offered,
+// validate the arguments, apply cooldowns and quotas, execute, and log. Every failure
+// becomes an error the model can read, never a silent skip.
+func (r *toolRegistry) Run(ctx context.Context, cfg Config, evt ChatEvent, offered []Tool, call ToolCall) ToolInvocation {
+	name := call.Function.Name
+	invocation := ToolInvocation{Name: name, Arguments: strings.TrimSpace(call.Function.Arguments)}
+	output, err := r.run(ctx, cfg, evt, offered, call)
+	if err != nil {
+		log.Printf("[TOOL] %s -> %s failed: %v", evt.Player, name, err)
+		invocation.Error = err.Error()
+	} else {
+		log.Printf("[TOOL] %s -> %s ok", evt.Player, name)
+		invocation.Output = output
+	}
+	return invocation
+}
+
+func (r *toolRegistry) run(ctx context.Context, cfg Config, evt ChatEvent, offered []Tool, call ToolCall) (string, error) {
+	name := call.Function.Name
+	tool := lookupTool(cfg, offered, name)
+	if tool == nil {
+		return "", fmt.Errorf("unknown tool %q", name)
+	}
+	if err := tool.Validate(cfg, call.Function.Arguments); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	refund, err := cfg.ToolPolicy.Use(evt.Player, name, time.Now())
+	if err != nil {
+		return "", err
+	}
+	output, err := tool.Execute(ctx, cfg, evt, call)
+	if err != nil {
+		refund()
+	}
+	return output, err
+}
+
+// RunAll executes every tool call from one model turn. Calls run concurrently, at most
+// cfg.ToolParallelism at a time, but a call waits for each earlier call that touches one
+// of the same players (or the world), so "take me home, then fireworks on me" keeps its
+// order. Results come back in call order, which is how the API expects tool messages.
+//
+// 🎓 LEARNING NOTE: Each tool shells out to the server, which takes a moment. Fireworks
+// for Steve and hearts for Alex don't depend on each other, so there is no reason for
+// one to wait in line behind the other.
+func (r *toolRegistry) RunAll(ctx context.Context, cfg Config, evt ChatEvent, offered []Tool, calls []ToolCall) []ToolInvocation {
+	results := make([]ToolInvocation, len(calls))
+	if len(calls) == 1 {
+		results[0] = r.Run(ctx, cfg, evt, offered, calls[0])
+		return results
+	}
+	limit := cfg.ToolParallelism
+	if limit < 1 {
+		limit = 1
+	}
+	slots := make(chan struct{}, limit)
+	done := make([]chan struct{}, len(calls))
+	touched := make([][]string, len(calls))
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		done[i] = make(chan struct{})
+		touched[i] = toolCallTouches(cfg, evt, lookupTool(cfg, offered, call.Function.Name), call)
+		var waitFor []chan struct{}
+		for j := 0; j < i; j++ {
+			if sharesAny(touched[i], touched[j]) {
+				waitFor = append(waitFor, done[j])
+			}
+		}
+		wg.Add(1)
+		go func(i int, call ToolCall, waitFor []chan struct{}) {
+			defer wg.Done()
+			defer close(done[i])
+			// Wait for earlier calls on the same players before taking a slot, so a
+			// blocked call never holds one (dependencies only point backwards).
+			for _, prior := range waitFor {
+				<-prior
+			}
+			slots <- struct{}{}
+			results[i] = r.Run(ctx, cfg, evt, offered, call)
+			<-slots
+		}(i, call, waitFor)
+	}
+	wg.Wait()
+	return results
+}
+
+// lookupTool finds a tool by name among the ones offered for this request.
+func lookupTool(cfg Config, offered []Tool, name string) Tool {
+	for _, tool := range offered {
+		if tool.Definition(cfg).Function.Name == name {
+			return tool
+		}
+	}
+	return nil
+}
+
+// worldKey stands for the whole world in toolCallTouches; time and weather share it.
+const worldKey = "@world"
+
+// toolCallTouches lists who a call acts on: the players named in its player arguments
+// (player, target_player, from_player, or any "format": "player" string), or the speaker
+// when none are given. Movement tools always include the speaker, who is the one moving,
+// and world tools touch the shared world key. Unknown tools touch nothing; they fail
+// without running anyway.
+func toolCallTouches(cfg Config, evt ChatEvent, tool Tool, call ToolCall) []string {
+	if tool == nil {
+		return nil
+	}
+	if tool.Category() == toolCategoryWorld {
+		return []string{worldKey}
+	}
+	var args map[string]interface{}
+	_ = json.Unmarshal([]byte(call.Function.Arguments), &args)
+	schema, _ := toolSchema(tool.Definition(cfg))
+	props, _ := schema["properties"].(map[string]interface{})
+	var players []string
+	for name, prop := range props {
+		value, _ := args[name].(string)
+		if value = strings.TrimSpace(value); value == "" || !isPlayerProperty(name, prop) {
+			continue
+		}
+		if resolved, err := cfg.Roster.Resolve(value); err == nil {
+			value = resolved
+		}
+		players = append(players, strings.ToLower(value))
+	}
+	if len(players) == 0 || tool.Category() == toolCategoryMovement {
+		players = append(players, strings.ToLower(evt.Player))
+	}
+	return players
+}
+
+// isPlayerProperty reports whether a schema property names a player.
+func isPlayerProperty(name string, schema interface{}) bool {
+	switch name {
+	case "player", "target_player", "from_player":
+		return true
+	}
+	prop, _ := schema.(map[string]interface{})
+	return prop["format"] == "player"
+}
+
+func sharesAny(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// toolSchema turns a definition's parameters into plain JSON values, whether they were
+// written as Go maps (built-ins) or structs (custom tools).
+func toolSchema(def ToolDefinition) (map[string]interface{}, error) {
+	data, err := json.Marshal(def.Function.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// validateToolArguments checks raw JSON arguments strictly against a tool's schema:
+// types, required fields, enums, bounds, and no properties beyond those declared.
+func validateToolArguments(def ToolDefinition, arguments string) error {
+	schema, err := toolSchema(def)
+	if err != nil {
+		return err
+	}
+	var value interface{} = map[string]interface{}{}
+	if strings.TrimSpace(arguments) != "" {
+		if err := json.Unmarshal([]byte(arguments), &value); err != nil {
+			return fmt.Errorf("arguments are not valid JSON: %w", err)
+		}
+	}
+	return validateSchemaValue(schema, value, "arguments")
+}
+
+// validateSchemaValue walks one value against the JSON schema subset the tools use.
+func validateSchemaValue(schema, value interface{}, path string) error {
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	switch s["type"] {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		props, _ := s["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			propSchema, declared := props[key]
+			if !declared {
+				return fmt.Errorf("%s has unexpected property %q", path, key)
+			}
+			if obj[key] == nil {
+				continue // null is treated like an omitted optional field
+			}
+			if err := validateSchemaValue(propSchema, obj[key], path+"."+key); err != nil {
+				return err
+			}
+		}
+		required, _ := s["required"].([]interface{})
+		for _, key := range required {
+			if name, _ := key.(string); obj[name] == nil {
+				return fmt.Errorf("%s is missing required property %q", path, name)
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		if enum, ok := s["enum"].([]interface{}); ok {
+			for _, allowed := range enum {
+				if a, _ := allowed.(string); strings.EqualFold(a, strings.TrimSpace(str)) {
+					return nil
+				}
+			}
+			return fmt.Errorf("%s must be one of %v", path, enum)
+		}
+	case "number", "integer":
+		n, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%s must be a number", path)
+		}
+		if s["type"] == "integer" && n != math.Trunc(n) {
+			return fmt.Errorf("%s must be a whole number", path)
+		}
+		if min, ok := s["minimum"].(float64); ok && n < min {
+			return fmt.Errorf("%s must be at least %v", path, min)
+		}
+		if max, ok := s["maximum"].(float64); ok && n > max {
+			return fmt.Errorf("%s must be at most %v", path, max)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be true or false", path)
+		}
+	}
+	return nil
+}
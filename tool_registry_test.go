@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSchemaTool is a definition exercising every schema rule the validator knows.
+var testSchemaTool = ToolDefinition{
+	Type: "function",
+	Function: ToolFunctionDefinition{
+		Name: "build_tower",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"material": map[string]interface{}{"type": "string", "enum": []string{"stone", "oak_planks"}},
+				"floors":   map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 10},
+				"scale":    map[string]interface{}{"type": "number", "minimum": 0.5},
+				"lit":      map[string]interface{}{"type": "boolean"},
+				"origin": map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"x": map[string]interface{}{"type": "number"}},
+					"required":   []string{"x"},
+				},
+			},
+			"required":             []string{"material"},
+			"additionalProperties": false,
+		},
+	},
+}
+
+func TestValidateToolArguments(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    string
+		wantErr string // substring naming the field; empty means valid
+	}{
+		{"minimal", `{"material":"stone"}`, ""},
+		{"empty arguments need required field", ``, `arguments is missing required property "material"`},
+		{"everything", `{"material":"Oak_Planks","floors":3,"scale":1.5,"lit":true,"origin":{"x":-4.5}}`, ""},
+		{"null optional", `{"material":"stone","floors":null}`, ""},
+		{"unexpected property", `{"material":"stone","command":"op Steve"}`, `arguments has unexpected property "command"`},
+		{"unexpected nested property", `{"material":"stone","origin":{"x":1,"y":2}}`, `arguments.origin has unexpected property "y"`},
+		{"missing required", `{"floors":2}`, `arguments is missing required property "material"`},
+		{"missing nested required", `{"material":"stone","origin":{}}`, `arguments.origin is missing required property "x"`},
+		{"enum mismatch", `{"material":"tnt"}`, "arguments.material must be one of"},
+		{"string for integer", `{"material":"stone","floors":"3"}`, "arguments.floors must be a number"},
+		{"float for integer", `{"material":"stone","floors":2.5}`, "arguments.floors must be a whole number"},
+		{"float for number", `{"material":"stone","scale":0.75}`, ""},
+		{"below minimum", `{"material":"stone","floors":0}`, "arguments.floors must be at least 1"},
+		{"above maximum", `{"material":"stone","floors":11}`, "arguments.floors must be at most 10"},
+		{"number below minimum", `{"material":"stone","scale":0.25}`, "arguments.scale must be at least 0.5"},
+		{"number for string", `{"material":7}`, "arguments.material must be a string"},
+		{"string for boolean", `{"material":"stone","lit":"yes"}`, "arguments.lit must be true or false"},
+		{"array for object", `{"material":"stone","origin":[1]}`, "arguments.origin must be an object"},
+		{"not an object", `["stone"]`, "arguments must be an object"},
+		{"broken json", `{"material":`, "arguments are not valid JSON"},
+	}
+	for _, tc := range cases {
+		err := validateToolArguments(testSchemaTool, tc.args)
+		switch {
+		case tc.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+			t.Errorf("%s: err = %v, want %q", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestRegistryReportsInvalidArgumentsToModel(t *testing.T) {
+	console := &fakeConsole{}
+	cfg := Config{Console: console, EnableToolUse: true}
+	registry, err := newToolRegistry(cfg, builtinTools()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := ToolCall{Function: ToolCallFunction{Name: teleportToolName, Arguments: `{"coordinates":{"x":"ten","y":64,"z":0}}`}}
+	inv := registry.Run(context.Background(), cfg, ChatEvent{Player: "Steve"}, registry.Available(cfg, "Steve"), call)
+	if want := "invalid arguments: arguments.coordinates.x must be a number"; inv.Error != want {
+		t.Fatalf("error = %q, want %q", inv.Error, want)
+	}
+	if commands := console.sent(); len(commands) != 0 {
+		t.Fatalf("commands = %q, want nothing run for invalid arguments", commands)
+	}
+}
+
+// blockingTool is a tool whose calls each wait for their own release, recording the
+// order they started in and how many ran at once.
+type blockingTool struct {
+	started chan string
+	release map[string]chan struct{}
+
+	mu         sync.Mutex
+	running    int
+	maxRunning int
+}
+
+func newBlockingTool(ids ...string) *blockingTool {
+	tool := &blockingTool{started: make(chan string, len(ids)), release: make(map[string]chan struct{})}
+	for _, id := range ids {
+		tool.release[id] = make(chan struct{})
+	}
+	return tool
+}
+
+func (t *blockingTool) Definition(Config) ToolDefinition {
+	return ToolDefinition{Type: "function", Function: ToolFunctionDefinition{
+		Name: "hold_still",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id":     map[string]interface{}{"type": "string"},
+				"player": map[string]interface{}{"type": "string"},
+			},
+			"required": []string{"id", "player"},
+		},
+	}}
+}
+
+func (t *blockingTool) Validate(cfg Config, arguments string) error {
+	return validateToolArguments(t.Definition(cfg), arguments)
+}
+
+func (t *blockingTool) Execute(_ context.Context, _ Config, _ ChatEvent, call ToolCall) (string, error) {
+	var args struct{ ID string }
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+		return "", err
+	}
+	t.mu.Lock()
+	t.running++
+	if t.running > t.maxRunning {
+		t.maxRunning = t.running
+	}
+	t.mu.Unlock()
+	t.started <- args.ID
+	<-t.release[args.ID]
+	t.mu.Lock()
+	t.running--
+	t.mu.Unlock()
+	return "done " + args.ID, nil
+}
+
+func (t *blockingTool) Category() toolCategory { return toolCategoryCustom }
+func (t *blockingTool) RequiresConsent() bool  { return false }
+
+// expectStarts waits for exactly these calls to start, in any order, and then checks
+// that nothing else starts.
+func (t *blockingTool) expectStarts(tb testing.TB, ids ...string) {
+	tb.Helper()
+	want := make(map[string]bool)
+	for _, id := range ids {
+		want[id] = true
+	}
+	for range ids {
+		select {
+		case id := <-t.started:
+			if !want[id] {
+				tb.Fatalf("call %s started, want one of %v", id, ids)
+			}
+			delete(want, id)
+		case <-time.After(2 * time.Second):
+			tb.Fatalf("calls %v never started", ids)
+		}
+	}
+	select {
+	case id := <-t.started:
+		tb.Fatalf("call %s started too early", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func holdCall(id, player string) ToolCall {
+	return ToolCall{Function: ToolCallFunction{Name: "hold_still", Arguments: fmt.Sprintf(`{"id":%q,"player":%q}`, id, player)}}
+}
+
+// runAllAsync runs the calls in the background and returns a channel for the results.
+func runAllAsync(cfg Config, tool *blockingTool, calls []ToolCall) <-chan []ToolInvocation {
+	registry, _ := newToolRegistry(cfg, tool)
+	results := make(chan []ToolInvocation, 1)
+	go func() {
+		results <- registry.RunAll(context.Background(), cfg, ChatEvent{Player: "Steve"}, []Tool{tool}, calls)
+	}()
+	return results
+}
+
+func checkResultOrder(t *testing.T, results []ToolInvocation, ids ...string) {
+	t.Helper()
+	if len(results) != len(ids) {
+		t.Fatalf("got %d results, want %d", len(results), len(ids))
+	}
+	for i, id := range ids {
+		if results[i].Error != "" || results[i].Output != "done "+id {
+			t.Errorf("result %d = %+v, want the output of call %s", i, results[i], id)
+		}
+	}
+}
+
+func TestRunAllKeepsSamePlayerOrder(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	tool := newBlockingTool("steve1", "alex1", "steve2", "steve3")
+	cfg := Config{EnableToolUse: true, ToolParallelism: 4}
+	done := runAllAsync(cfg, tool, []ToolCall{
+		holdCall("steve1", "Steve"),
+		holdCall("alex1", "Alex"),
+		holdCall("steve2", "steve"),
+		holdCall("steve3", "Steve"),
+	})
+
+	// Alex does not wait behind Steve, but each Steve call waits for the one before.
+	tool.expectStarts(t, "steve1", "alex1")
+	close(tool.release["steve1"])
+	tool.expectStarts(t, "steve2")
+	close(tool.release["steve2"])
+	tool.expectStarts(t, "steve3")
+	close(tool.release["steve3"])
+	close(tool.release["alex1"])
+
+	checkResultOrder(t, <-done, "steve1", "alex1", "steve2", "steve3")
+}
+
+func TestRunAllBoundsParallelism(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	ids := []string{"a", "b", "c", "d"}
+	tool := newBlockingTool(ids...)
+	cfg := Config{EnableToolUse: true, ToolParallelism: 2}
+	done := runAllAsync(cfg, tool, []ToolCall{
+		holdCall("a", "Alex"),
+		holdCall("b", "Steve"),
+		holdCall("c", "Notch"),
+		holdCall("d", "Jeb"),
+	})
+
+	// Which two calls win the slots first is up to the scheduler; count them instead.
+	first := []string{<-tool.started, <-tool.started}
+	select {
+	case id := <-tool.started:
+		t.Fatalf("call %s started while both slots were taken", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(tool.release[first[0]])
+	select {
+	case <-tool.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("freeing a slot did not start the next call")
+	}
+	for _, id := range ids {
+		if id != first[0] {
+			close(tool.release[id])
+		}
+	}
+
+	checkResultOrder(t, <-done, ids...)
+	if tool.maxRunning != 2 {
+		t.Fatalf("max concurrent calls = %d, want 2", tool.maxRunning)
+	}
+}
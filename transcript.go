@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// transcriptLine is one server event in the full chat transcript. IDs only grow, across
+// restarts and rotations, so an interaction log entry or incident can point at the exact
+// line that triggered it.
+type transcriptLine struct {
+	ID     int64  `json:"id"`
+	Time   string `json:"time"`
+	Kind   string `json:"kind"`
+	Player string `json:"player,omitempty"`
+	Text   string `json:"text"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// transcriptRecorder writes every chat line and server event the log watcher reports,
+// answered or not, to MCCHATBOT_TRANSCRIPT. It shares the interaction log's rotation,
+// compression, and retention settings.
+//
+// 🎓 LEARNING NOTE: The interaction log only has the lines Alfred answered. When a
+// parent asks "what happened before my kid got upset?", staff need the whole
+// conversation - this is the security-camera tape, not just the highlights.
+type transcriptRecorder struct {
+	log  *rotatingLog
+	last atomic.Int64
+}
+
+// newTranscriptRecorder opens the transcript and continues numbering after the highest
+// ID already on disk. An empty path disables the transcript and returns nil.
+func newTranscriptRecorder(path string, maxBytes int64, daily, compress bool, retention time.Duration) (*transcriptRecorder, error) {
+	if path == "" {
+		return nil, nil
+	}
+	last, err := lastTranscriptID(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	l, err := newRotatingLog(path, maxBytes, daily, compress, retention)
+	if err != nil {
+		return nil, err
+	}
+	t := &transcriptRecorder{log: l}
+	t.last.Store(last)
+	return t, nil
+}
+
+// Record appends the event and returns its line ID. Roster replies to our own `list`
+// command are bookkeeping, not conversation, so they are skipped (ID 0).
+func (t *transcriptRecorder) Record(evt ChatEvent) int64 {
+	if t == nil || evt.Kind == EventPlayerList {
+		return 0
+	}
+	when := evt.Time
+	if when.IsZero() {
+		when = time.Now()
+	}
+	line := transcriptLine{
+		ID:     t.last.Add(1),
+		Time:   when.Format(time.RFC3339),
+		Kind:   evt.Kind.String(),
+		Player: evt.Player,
+		Text:   evt.Text,
+		Detail: evt.Detail,
+	}
+	data, err := json.Marshal(line)
+	if err == nil {
+		err = t.log.Write(data)
+	}
+	if err != nil {
+		return 0
+	}
+	return line.ID
+}
+
+// Close flushes the transcript. A nil recorder is a no-op.
+func (t *transcriptRecorder) Close() error {
+	if t == nil {
+		return nil
+	}
+	return t.log.Close()
+}
+
+// lastTranscriptID finds the highest ID in the newest segment that has any lines.
+func lastTranscriptID(path string) (int64, error) {
+	segments, err := logSegments(path)
+	if err != nil {
+		return 0, err
+	}
+	for i := len(segments) - 1; i >= 0; i-- {
+		var last int64
+		err := scanLogSegment(segments[i], func(data []byte) bool {
+			var line transcriptLine
+			if json.Unmarshal(data, &line) == nil && line.ID > last {
+				last = line.ID
+			}
+			return true
+		})
+		if err != nil {
+			return 0, err
+		}
+		if last > 0 {
+			return last, nil
+		}
+	}
+	return 0, nil
+}
+
+// readTranscript returns the lines with IDs from first to last, inclusive, oldest first.
+// Segments are read in order and the scan stops once it passes last.
+func readTranscript(path string, first, last int64) ([]transcriptLine, error) {
+	segments, err := logSegments(path)
+	if err != nil {
+		return nil, err
+	}
+	var lines []transcriptLine
+	done := false
+	for _, segment := range segments {
+		err := scanLogSegment(segment, func(data []byte) bool {
+			var line transcriptLine
+			if json.Unmarshal(data, &line) != nil {
+				return true
+			}
+			if line.ID > last {
+				done = true
+				return false
+			}
+			if line.ID >= first {
+				lines = append(lines, line)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", segment, err)
+		}
+		if done {
+			break
+		}
+	}
+	return lines, nil
+}
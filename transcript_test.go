@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordTranscript opens the transcript, records one chat line per text, and closes it,
+// returning the IDs it handed out.
+func recordTranscript(t *testing.T, path string, maxBytes int64, texts ...string) []int64 {
+	t.Helper()
+	recorder, err := newTranscriptRecorder(path, maxBytes, false, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 7, 1, 14, 0, 0, 0, time.UTC)
+	var ids []int64
+	for i, text := range texts {
+		ids = append(ids, recorder.Record(ChatEvent{Kind: EventChat, Player: "Steve", Text: text, Time: start.Add(time.Duration(i) * time.Second)}))
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func numberedTexts(from, to int) []string {
+	var texts []string
+	for i := from; i <= to; i++ {
+		texts = append(texts, fmt.Sprintf("message %d", i))
+	}
+	return texts
+}
+
+func TestTranscriptIDsContinueAcrossRestartAndRotation(t *testing.T) {
+	quietLogs(t)
+	path := filepath.Join(t.TempDir(), "transcript.jsonl")
+
+	// A 200-byte cap rotates (and gzips) every couple of lines.
+	if ids := recordTranscript(t, path, 200, numberedTexts(1, 6)...); fmt.Sprint(ids) != "[1 2 3 4 5 6]" {
+		t.Fatalf("first run IDs = %v", ids)
+	}
+	if len(rotatedSegments(t, path)) == 0 {
+		t.Fatal("expected the small cap to rotate the transcript")
+	}
+	if ids := recordTranscript(t, path, 200, numberedTexts(7, 9)...); fmt.Sprint(ids) != "[7 8 9]" {
+		t.Fatalf("IDs after restart = %v, want numbering to continue", ids)
+	}
+
+	// A restart right after rotation finds an empty live file and falls back to the
+	// newest segment.
+	ext := filepath.Ext(path)
+	if err := os.Rename(path, strings.TrimSuffix(path, ext)+"-29991231-235959"+ext); err != nil {
+		t.Fatal(err)
+	}
+	if ids := recordTranscript(t, path, 200, "message 10"); fmt.Sprint(ids) != "[10]" {
+		t.Fatalf("IDs after restart with an empty live file = %v", ids)
+	}
+
+	recorder, err := newTranscriptRecorder(path, 200, false, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := recorder.Record(ChatEvent{Kind: EventPlayerList, Text: "There are 1 of a max of 20 players online: Steve"}); id != 0 {
+		t.Errorf("roster reply got ID %d, want it skipped", id)
+	}
+	if id := recorder.Record(ChatEvent{Kind: EventJoin, Player: "Alex", Text: "Alex joined the game"}); id != 11 {
+		t.Errorf("join after a skipped roster reply got ID %d, want 11", id)
+	}
+	recorder.Close()
+
+	lines, err := readTranscript(path, 1, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range lines {
+		if line.ID != int64(i+1) {
+			t.Fatalf("line %d has ID %d; IDs should run 1..11 without gaps or repeats", i, line.ID)
+		}
+	}
+	if len(lines) != 11 {
+		t.Fatalf("read %d lines, want 11", len(lines))
+	}
+}
+
+func TestReadTranscriptIncidentWindow(t *testing.T) {
+	quietLogs(t)
+	path := filepath.Join(t.TempDir(), "transcript.jsonl")
+	recordTranscript(t, path, 300, numberedTexts(1, 30)...)
+
+	const context = 3
+	cases := []struct {
+		line     int64
+		from, to int64
+	}{
+		{line: 12, from: 9, to: 15},
+		{line: 2, from: 1, to: 5},    // window clipped at the start
+		{line: 29, from: 26, to: 30}, // and at the end
+	}
+	for _, tc := range cases {
+		lines, err := readTranscript(path, tc.line-context, tc.line+context)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lines) != int(tc.to-tc.from+1) || lines[0].ID != tc.from || lines[len(lines)-1].ID != tc.to {
+			t.Errorf("line %d: got %d lines %+v, want IDs %d..%d", tc.line, len(lines), lines, tc.from, tc.to)
+			continue
+		}
+		trigger := lines[tc.line-tc.from]
+		if trigger.ID != tc.line || trigger.Text != fmt.Sprintf("message %d", tc.line) || trigger.Player != "Steve" {
+			t.Errorf("line %d: trigger = %+v", tc.line, trigger)
+		}
+	}
+
+	if lines, err := readTranscript(path, 40, 50); err != nil || len(lines) != 0 {
+		t.Errorf("window past the end = %+v, %v; want nothing", lines, err)
+	}
+}
+
+func TestPrintIncidentsMarksTrigger(t *testing.T) {
+	quietLogs(t)
+	path := filepath.Join(t.TempDir(), "transcript.jsonl")
+	recordTranscript(t, path, 0, numberedTexts(1, 5)...)
+	lines, err := readTranscript(path, 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	printIncidents(&out, []incidentExport{{Record: interactionRecord{Player: "Steve", Line: 3}, Lines: lines}})
+	if !strings.Contains(out.String(), ">> 3 ") || strings.Contains(out.String(), ">> 2 ") || !strings.Contains(out.String(), "<Steve> message 3") {
+		t.Fatalf("incident output:\n%s", out.String())
+	}
+}
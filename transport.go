@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// CommandTransport delivers one console command to the Minecraft server and returns the
+// server's textual reply when the transport can see it. Every console side effect in the
+// bot flows through this interface so the delivery mechanism is a config choice.
+//
+// 🎓 LEARNING NOTE: An interface is a "contract" - anything with Run and Close methods
+// can be a transport. That is why screen and RCON are interchangeable here!
+type CommandTransport interface {
+	Run(ctx context.Context, command string) (string, error)
+	Close() error
+}
+
+const (
+	transportScreen = "screen"
+	transportRcon   = "rcon"
+)
+
+// consoleFailureMarkers are reply fragments the server prints when a command did nothing.
+// RCON surfaces them so tool executors can report real failures back to the LLM. Each
+// is a multi-word phrase from the server's own messages: usernames cannot contain
+// spaces, so a player called InvalidGamer never turns a good reply into an error.
+var consoleFailureMarkers = []string{
+	"no player was found",
+	"no entity was found",
+	"no targets matched",
+	"unknown or incomplete command",
+	"incorrect argument for command",
+	"unknown command",
+	"that position is not loaded",
+	"invalid name or uuid",
+	"expected whitespace to end one argument",
+}
+
+// newCommandTransport builds the transport selected by MCCHATBOT_TRANSPORT.
+// Unknown values are rejected so typos never silently fall back to screen.
+func newCommandTransport(cfg Config) (CommandTransport, error) {
+	switch cfg.Transport {
+	case "", transportScreen:
+		return guardedTransport{CommandTransport: screenTransport{session: cfg.ScreenSession}, screen: true}, nil
+	case transportRcon:
+		if cfg.RconAddress == "" {
+			return nil, errors.New("MCCHATBOT_RCON_ADDR is required for the rcon transport")
+		}
+		if cfg.RconPassword == "" {
+			return nil, errors.New("MCCHATBOT_RCON_PASSWORD is required for the rcon transport")
+		}
+		return guardedTransport{CommandTransport: newRconTransport(cfg.RconAddress, cfg.RconPassword, cfg.RconTimeout)}, nil
+	default:
+		return nil, fmt.Errorf("unknown transport %q (expected %s or %s)", cfg.Transport, transportScreen, transportRcon)
+	}
+}
+
+// screenTransport stuffs keystrokes into a detached screen session. It has no way to
+// read the console back, so replies are always empty.
+type screenTransport struct {
+	session string
+}
+
+// Run types the command plus a carriage return into window 0 of the screen session.
+func (s screenTransport) Run(ctx context.Context, command string) (string, error) {
+	cmd := exec.CommandContext(ctx, "screen", "-S", s.session, "-p", "0", "-X", "stuff", command+"\r")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return "", cmd.Run()
+}
+
+// Close is a no-op because every screen command is its own short-lived process.
+func (screenTransport) Close() error { return nil }
+
+// consoleReplyError turns a server reply that signals failure into an error so the LLM
+// hears "No player was found" instead of a cheerful success message.
+func consoleReplyError(reply string) error {
+	lower := strings.ToLower(reply)
+	for _, marker := range consoleFailureMarkers {
+		if strings.Contains(lower, marker) {
+			return fmt.Errorf("server replied: %s", strings.TrimSpace(reply))
+		}
+	}
+	return nil
+}
+
+// withConsoleReply appends the server's reply to a tool summary when one is available.
+func withConsoleReply(summary, reply string) string {
+	reply = strings.TrimSpace(reply)
+	if reply == "" {
+		return summary
+	}
+	return fmt.Sprintf("%s Server replied: %s", summary, reply)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// waypointNamePattern keeps waypoint names short, lowercase, and safe in a schema enum.
+	waypointNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,24}$`)
+	// entityPosPattern and entityDimPattern read `data get entity <player> Pos|Dimension`.
+	entityPosPattern = regexp.MustCompile(`\[(-?[\d.]+)d, (-?[\d.]+)d, (-?[\d.]+)d\]`)
+	entityDimPattern = regexp.MustCompile(`"([a-z0-9_.-]+:[a-z0-9_./-]+)"`)
+)
+
+// waypoint is a saved place in a specific dimension.
+type waypoint struct {
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	Z         float64 `json:"z"`
+	Dimension string  `json:"dimension"`
+}
+
+func (w waypoint) coords() coordinateArguments {
+	return coordinateArguments{X: w.X, Y: w.Y, Z: w.Z}
+}
+
+// String renders "x y z in dimension" for chat replies.
+func (w waypoint) String() string {
+	return fmt.Sprintf("%s in %s", coordinateLabel(w.coords()), w.Dimension)
+}
+
+// waypointStore keeps the staff-defined camp locations ("village", "arena") and each
+// camper's home, saved to a JSON file so they survive restarts.
+//
+// 🎓 LEARNING NOTE: A waypoint is just a name for coordinates. "Take me to the arena"
+// is much easier for a camper to remember than "tp 120 64 -340"!
+type waypointStore struct {
+	mu    sync.Mutex
+	path  string
+	Camp  map[string]waypoint `json:"camp"`
+	Homes map[string]waypoint `json:"homes"`
+}
+
+// newWaypointStore builds the store and reloads the saved file. A missing file is fine;
+// a corrupt one is reported.
+func newWaypointStore(path string) (*waypointStore, error) {
+	s := &waypointStore{path: path, Camp: make(map[string]waypoint), Homes: make(map[string]waypoint)}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Camp == nil {
+		s.Camp = make(map[string]waypoint)
+	}
+	if s.Homes == nil {
+		s.Homes = make(map[string]waypoint)
+	}
+	return s, nil
+}
+
+// Names returns the camp waypoint names, sorted, for the teleport schema enum.
+func (s *waypointStore) Names() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.Camp))
+	for name := range s.Camp {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get looks up a camp waypoint by name.
+func (s *waypointStore) Get(name string) (waypoint, bool) {
+	if s == nil {
+		return waypoint{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wp, ok := s.Camp[strings.ToLower(name)]
+	return wp, ok
+}
+
+// Set adds or moves a camp waypoint.
+func (s *waypointStore) Set(name string, wp waypoint) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !waypointNamePattern.MatchString(name) || name == "spawn" || name == "home" {
+		return fmt.Errorf("waypoint names use a-z, 0-9, _ or - (up to 24), and can't be spawn or home")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Camp[name] = wp
+	return s.saveLocked()
+}
+
+// Remove deletes a camp waypoint and reports whether it existed.
+func (s *waypointStore) Remove(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name = strings.ToLower(name)
+	if _, ok := s.Camp[name]; !ok {
+		return false, nil
+	}
+	delete(s.Camp, name)
+	return true, s.saveLocked()
+}
+
+// Home returns the player's saved home.
+func (s *waypointStore) Home(player string) (waypoint, bool) {
+	if s == nil {
+		return waypoint{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wp, ok := s.Homes[strikeKey(player)]
+	return wp, ok
+}
+
+// SetHome saves the player's home.
+func (s *waypointStore) SetHome(player string, wp waypoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Homes[strikeKey(player)] = wp
+	return s.saveLocked()
+}
+
+// saveLocked writes the store atomically (temp file + rename), like the strike ledger.
+func (s *waypointStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".waypoints-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// lookupDestination resolves a teleport destination name for the requester: "home" is
+// their own saved home, anything else a camp waypoint.
+func lookupDestination(cfg Config, requester, name string) (waypoint, string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "home" {
+		wp, ok := cfg.Waypoints.Home(requester)
+		if !ok {
+			return waypoint{}, "", fmt.Errorf("%s has no home yet; they can stand somewhere cozy and say %s sethome", requester, cfg.TriggerWord)
+		}
+		return wp, "home", nil
+	}
+	wp, ok := cfg.Waypoints.Get(name)
+	if !ok {
+		known := append([]string{"spawn", "home"}, cfg.Waypoints.Names()...)
+		return waypoint{}, "", fmt.Errorf("unknown destination %q (known: %s)", name, strings.Join(known, ", "))
+	}
+	return wp, name, nil
+}
+
+// teleportToWaypoint moves a player to a saved place, switching dimension when needed.
+func teleportToWaypoint(ctx context.Context, cfg Config, player string, wp waypoint) (string, error) {
+	player, err := validatePlayerName(player)
+	if err != nil {
+		return "", err
+	}
+	if !resourceLocationPattern.MatchString(wp.Dimension) {
+		return "", fmt.Errorf("waypoint has an invalid dimension %q", wp.Dimension)
+	}
+	command := fmt.Sprintf("execute in %s run tp %s %s", wp.Dimension, player, coordinateLabel(wp.coords()))
+	return runConsoleCommand(ctx, cfg, command)
+}
+
+// playerPosition asks the server where a player is standing. Only RCON returns command
+// output, so with the screen transport the reply is empty and this fails.
+func playerPosition(ctx context.Context, cfg Config, player string) (waypoint, error) {
+	player, err := validatePlayerName(player)
+	if err != nil {
+		return waypoint{}, err
+	}
+	posReply, err := runConsoleCommand(ctx, cfg, fmt.Sprintf("data get entity %s Pos", player))
+	if err != nil {
+		return waypoint{}, err
+	}
+	m := entityPosPattern.FindStringSubmatch(posReply)
+	if m == nil {
+		return waypoint{}, errors.New("couldn't read the position (saving places needs MCCHATBOT_TRANSPORT=rcon)")
+	}
+	var wp waypoint
+	for i, field := range []*float64{&wp.X, &wp.Y, &wp.Z} {
+		value, err := strconv.ParseFloat(m[i+1], 64)
+		if err != nil {
+			return waypoint{}, err
+		}
+		*field = math.Round(value*10) / 10
+	}
+	dimReply, err := runConsoleCommand(ctx, cfg, fmt.Sprintf("data get entity %s Dimension", player))
+	if err != nil {
+		return waypoint{}, err
+	}
+	if d := entityDimPattern.FindStringSubmatch(dimReply); d != nil {
+		wp.Dimension = d[1]
+	} else {
+		wp.Dimension = cfg.SpawnDimension
+	}
+	return wp, nil
+}
+
+// runSetHomeCommand saves the caller's current position as their home.
+func runSetHomeCommand(ctx context.Context, cfg Config, evt ChatEvent, _ []string) string {
+	wp, err := playerPosition(ctx, cfg, evt.Player)
+	if err != nil {
+		log.Printf("sethome error for %s: %v", evt.Player, err)
+		return fmt.Sprintf("Sorry, I couldn't save your home: %v", err)
+	}
+	if err := cfg.Waypoints.SetHome(evt.Player, wp); err != nil {
+		log.Printf("waypoint save error: %v", err)
+	}
+	return fmt.Sprintf("Home saved at %s. Ask me to take you home any time!", wp)
+}
+
+// runWaypointCommand lets staff list, set, and remove camp waypoints. "set" uses the
+// staff member's own position unless coordinates (and optionally a dimension) are given.
+func runWaypointCommand(ctx context.Context, cfg Config, evt ChatEvent, args []string) string {
+	usage := fmt.Sprintf("Usage: %s waypoint list | set <name> [x y z [dimension]] | remove <name>", cfg.TriggerWord)
+	if cfg.Waypoints == nil || len(args) == 0 {
+		return usage
+	}
+	switch strings.ToLower(args[0]) {
+	case "list":
+		names := cfg.Waypoints.Names()
+		if len(names) == 0 {
+			return "No camp waypoints yet."
+		}
+		parts := make([]string, 0, len(names))
+		for _, name := range names {
+			wp, _ := cfg.Waypoints.Get(name)
+			parts = append(parts, fmt.Sprintf("%s (%s)", name, wp))
+		}
+		return "Waypoints: " + strings.Join(parts, "; ")
+	case "set":
+		if len(args) != 2 && len(args) != 5 && len(args) != 6 {
+			return usage
+		}
+		var wp waypoint
+		var err error
+		if len(args) == 2 {
+			wp, err = playerPosition(ctx, cfg, evt.Player)
+		} else {
+			wp, err = parseWaypointArgs(cfg, args[2:])
+		}
+		if err == nil {
+			err = cfg.Waypoints.Set(args[1], wp)
+		}
+		if err != nil {
+			return fmt.Sprintf("Couldn't set waypoint: %v", err)
+		}
+		return fmt.Sprintf("Waypoint %s set at %s.", strings.ToLower(args[1]), wp)
+	case "remove":
+		if len(args) != 2 {
+			return usage
+		}
+		removed, err := cfg.Waypoints.Remove(args[1])
+		if err != nil {
+			log.Printf("waypoint save error: %v", err)
+		}
+		if !removed {
+			return fmt.Sprintf("There is no waypoint called %s.", args[1])
+		}
+		return fmt.Sprintf("Removed waypoint %s.", strings.ToLower(args[1]))
+	}
+	return usage
+}
+
+// parseWaypointArgs reads "x y z [dimension]" from an admin command.
+func parseWaypointArgs(cfg Config, args []string) (waypoint, error) {
+	coords, err := parseSpawnPoint(strings.Join(args[:3], ","))
+	if err != nil {
+		return waypoint{}, err
+	}
+	wp := waypoint{X: coords[0], Y: coords[1], Z: coords[2], Dimension: cfg.SpawnDimension}
+	if len(args) == 4 {
+		wp.Dimension = strings.ToLower(args[3])
+		if !resourceLocationPattern.MatchString(wp.Dimension) {
+			return waypoint{}, fmt.Errorf("dimension %q must look like minecraft:the_nether", args[3])
+		}
+	}
+	return wp, nil
+}